@@ -0,0 +1,46 @@
+package testivus
+
+import (
+	"encoding/json"
+	"flag"
+	"io"
+	"strconv"
+)
+
+func init() {
+	fileFlags["badge"] = flag.String("testivus.badgefile", "", "write a shields.io endpoint badge with the number of disappointments")
+	outputWriters["badge"] = writeBadge
+}
+
+var badgeGreen, badgeRed = 0, 10
+
+// SetBadgeThresholds sets the badge colors: green up to green disappointments,
+// red from red on, and yellow in between. The defaults are 0 and 10.
+func SetBadgeThresholds(green, red int) {
+	badgeGreen, badgeRed = green, red
+}
+
+// badgeColor is the color of the badge for total disappointments.
+func badgeColor(total int) string {
+	switch {
+	case total <= badgeGreen:
+		return "green"
+	case total < badgeRed:
+		return "yellow"
+	}
+	return "red"
+}
+
+// writeBadge saves a shields.io endpoint badge with the number of
+// disappointments to path.
+func writeBadge(path string, d *disappointments) error {
+	total := d.Summary.Total
+	return writeFile(path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(map[string]interface{}{
+			"schemaVersion": 1,
+			"label":         "disappointments",
+			"message":       strconv.Itoa(total),
+			"color":         badgeColor(total),
+		})
+	})
+}
@@ -0,0 +1,48 @@
+package testivus
+
+import "testing"
+
+// Batch buffers grievances for a test and registers them all at once, so hot
+// loops don't fight over the lock. A Batch is not safe for concurrent use.
+type Batch struct {
+	t       testing.TB
+	pending []*disappointment
+}
+
+// NewBatch starts a batch of grievances for t. Anything still buffered is
+// flushed when the test finishes.
+func NewBatch(t testing.TB) *Batch {
+	b := &Batch{t: t}
+	t.Cleanup(b.Flush)
+	return b
+}
+
+// Add buffers a grievance until the next Flush.
+func (b *Batch) Add(msg string, tags ...string) Disappointment {
+	g := newGrievance(b.t.Name(), msg, tags)
+	g.t = b.t
+	classify(g)
+	b.pending = append(b.pending, g)
+	return g
+}
+
+// Flush registers all buffered grievances, taking the lock once.
+func (b *Batch) Flush() {
+	if len(b.pending) == 0 {
+		return
+	}
+
+	b.t.Helper()
+	pending := b.pending
+	b.pending = nil
+
+	running.Lock()
+	for _, g := range pending {
+		running.add(b.t, g)
+	}
+	running.Unlock()
+
+	for _, g := range pending {
+		registered(b.t, g)
+	}
+}
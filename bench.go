@@ -0,0 +1,39 @@
+package testivus
+
+import (
+	"testing"
+	"time"
+)
+
+// benchMetricUnit is the unit TimedB reports its measurement under.
+var benchMetricUnit = "testivus-ns/op"
+
+// SetBenchMetricUnit sets the unit TimedB reports its per-op duration under,
+// which is also the metric's name in benchmark output and benchstat.
+func SetBenchMetricUnit(unit string) {
+	benchMetricUnit = unit
+}
+
+// TimedB times a benchmark from the call until the returned func is called.
+// The duration per op is reported with b.ReportMetric and, if it is over
+// budget, registered as a grievance.
+//
+//	defer testivus.TimedB(b, time.Millisecond, "too slow", "speed")()
+func TimedB(b *testing.B, budget time.Duration, msg string, tags ...string) func() {
+	start := now()
+	return func() {
+		b.Helper()
+		perOp := now().Sub(start) / time.Duration(b.N)
+		b.ReportMetric(float64(perOp.Nanoseconds()), benchMetricUnit)
+		if perOp <= budget {
+			return
+		}
+
+		running.Lock()
+		g := running.record(b, b.Name(), msg, tags)
+		running.Unlock()
+
+		g.WithDuration(perOp)
+		registered(b, g)
+	}
+}
@@ -0,0 +1,66 @@
+package testivus
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// boxed renders each section of the report as an ASCII bordered table, for
+// pasting where trailing whitespace and tabs don't survive.
+func (d *disappointments) boxed() string {
+	d.Lock()
+	defer d.Unlock()
+
+	s := d.summarize()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "I got a lot of problems with you people! (%d disappointments across %d tests)\n", s.Total, s.Tests)
+	box := func(title, column string, rows []reportRow) {
+		if len(rows) == 0 {
+			return
+		}
+		b.WriteString("\n" + title + ":\n")
+		b.WriteString(boxTable(column, rows))
+	}
+	box("By Tag", "tag", s.tagRows)
+	box("Tags Together", "tags", s.pairRows)
+	box("By Error", "error", s.errorRows)
+	for _, dr := range s.dimensionRows {
+		box("By "+dr.Name, dr.Name, dr.Rows)
+	}
+	box("By File", "file", s.fileRows)
+	box("By Source Package", "package", s.packageRows)
+	box("By Fuzz Target", "fuzz target", s.fuzzRows)
+	box("By Test", "test", s.nameRows)
+	return b.String()
+}
+
+// boxTable renders rows as a bordered table of IDs and counts.
+func boxTable(column string, rows []reportRow) string {
+	idWidth, countWidth := utf8.RuneCountInString(column), len("count")
+	for _, r := range rows {
+		if n := utf8.RuneCountInString(r.ID); n > idWidth {
+			idWidth = n
+		}
+		if n := len(r.Numbers()); n > countWidth {
+			countWidth = n
+		}
+	}
+
+	border := "+" + strings.Repeat("-", idWidth+2) + "+" + strings.Repeat("-", countWidth+2) + "+\n"
+	line := func(id, count string) string {
+		pad := strings.Repeat(" ", idWidth-utf8.RuneCountInString(id))
+		return fmt.Sprintf("| %s%s | %*s |\n", id, pad, countWidth, count)
+	}
+
+	var b strings.Builder
+	b.WriteString(border)
+	b.WriteString(line(column, "count"))
+	b.WriteString(border)
+	for _, r := range rows {
+		b.WriteString(line(r.ID, r.Numbers()))
+	}
+	b.WriteString(border)
+	return b.String()
+}
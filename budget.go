@@ -0,0 +1,51 @@
+package testivus
+
+import (
+	"fmt"
+	"strings"
+)
+
+var perTestBudget int
+
+// SetPerTestBudget fails the run when any single test registers more than n
+// grievances, even if the totals look fine. Run lists the offending tests,
+// and the default exit policy exits nonzero. A budget of zero, the default,
+// allows any number.
+func SetPerTestBudget(n int) {
+	perTestBudget = n
+}
+
+// OverBudget counts the grievances of each test over SetPerTestBudget. It is
+// empty when no budget is set.
+func (r *Report) OverBudget() map[string]int {
+	over := make(map[string]int)
+	if perTestBudget <= 0 {
+		return over
+	}
+	for name, c := range r.s.ByName {
+		if c > perTestBudget {
+			over[name] = c
+		}
+	}
+	return over
+}
+
+// overBudget lists the tests over the per-test budget, most disappointing
+// first, or returns "" if there are none.
+func overBudget(r *Report) string {
+	over := r.OverBudget()
+	if len(over) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, row := range r.s.nameRows {
+		if c, ok := over[row.ID]; ok {
+			fmt.Fprintf(&b, "\t%s: %d grievances\n", row.ID, c)
+		}
+	}
+	if b.Len() == 0 {
+		return ""
+	}
+	return fmt.Sprintf("tests over the budget of %d grievances:\n%s", perTestBudget, b.String())
+}
@@ -0,0 +1,122 @@
+package testivus
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// checkpoint is the disappointments along with the bookkeeping a resumed run
+// needs that the report itself leaves out.
+type checkpoint struct {
+	*disappointments
+	Once       map[string]string `json:"once,omitempty"`
+	Tests      []string          `json:"tests,omitempty"`
+	Dropped    map[string]int    `json:"dropped,omitempty"`
+	Suppressed int               `json:"suppressed,omitempty"`
+	Skipped    int               `json:"skipped,omitempty"`
+}
+
+// Checkpoint saves the grievances registered so far to path, so a run that
+// crashes can be resumed with Load. Run resumes from -testivus.checkpoint
+// automatically.
+func Checkpoint(path string) error {
+	return running.checkpoint(path)
+}
+
+func (d *disappointments) checkpoint(path string) error {
+	if err := localCheckpoint(path); err != nil {
+		return err
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	d.recheck()
+	c := checkpoint{
+		disappointments: d.withStored(),
+		Once:            make(map[string]string, len(d.once)),
+		Dropped:         d.dropped,
+		Suppressed:      d.suppressed,
+		Skipped:         d.skipped,
+	}
+	for key, g := range d.once {
+		c.Once[key] = g.UID
+	}
+	for name := range d.tests {
+		c.Tests = append(c.Tests, name)
+	}
+	sort.Strings(c.Tests)
+
+	// writeFile renames into place, so a crash never leaves a torn checkpoint
+	return writeFile(path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(c)
+	})
+}
+
+// Load adds the grievances saved by Checkpoint to the current run, along with
+// which tests failed and the counts of dropped, suppressed and skipped
+// grievances.
+func Load(path string) error {
+	return running.load(path)
+}
+
+func (d *disappointments) load(path string) error {
+	if err := localCheckpoint(path); err != nil {
+		return err
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "open checkpoint")
+	}
+	defer f.Close()
+
+	saved := checkpoint{disappointments: newDisappointments(nil)}
+	if err := newDecoder(f).Decode(&saved); err != nil {
+		return errors.Wrap(err, "decode checkpoint")
+	}
+
+	d.Lock()
+	defer d.Unlock()
+
+	byID := make(map[string]*disappointment)
+	for _, v := range saved.Grievances {
+		for _, g := range v {
+			d.store.Add(g)
+			d.stored[g.Name]++
+			byID[g.UID] = g
+		}
+	}
+	for key, id := range saved.Once {
+		if g, ok := byID[id]; ok {
+			d.once[key] = g
+		}
+	}
+
+	for name, failed := range saved.Failed {
+		d.Failed[name] = d.Failed[name] || failed
+	}
+	for _, name := range saved.Tests {
+		d.tests[name] = true
+	}
+	for name, n := range saved.Dropped {
+		d.dropped[name] += n
+	}
+	d.suppressed += saved.Suppressed
+	d.skipped += saved.Skipped
+	return nil
+}
+
+// localCheckpoint rejects checkpoints at URLs the reports could be uploaded
+// to, since a checkpoint has to be read back from where it was saved.
+func localCheckpoint(path string) error {
+	if _, ok := uploadScheme(path); ok {
+		return fmt.Errorf("checkpoint %s must be a local file", path)
+	}
+	return nil
+}
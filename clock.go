@@ -0,0 +1,15 @@
+package testivus
+
+import "time"
+
+// now is the clock used for all testivus timing.
+var now = time.Now
+
+// SetClock replaces the clock testivus uses for timing, so tests can supply a
+// fake clock and get deterministic durations. Passing nil restores time.Now.
+func SetClock(clock func() time.Time) {
+	if clock == nil {
+		clock = time.Now
+	}
+	now = clock
+}
@@ -0,0 +1,36 @@
+package testivus
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+var (
+	commit     string
+	commitOnce sync.Once
+)
+
+// SetCommit sets the commit the report is attributed to, overriding the one
+// found in the binary's build info.
+func SetCommit(sha string) {
+	commitOnce.Do(func() {})
+	commit = sha
+}
+
+// currentCommit is the commit set with SetCommit, or else the VCS revision
+// stamped into the binary. It is empty when neither is available, which is
+// common for test binaries.
+func currentCommit() string {
+	commitOnce.Do(func() {
+		info, ok := debug.ReadBuildInfo()
+		if !ok {
+			return
+		}
+		for _, s := range info.Settings {
+			if s.Key == "vcs.revision" {
+				commit = s.Value
+			}
+		}
+	})
+	return commit
+}
@@ -0,0 +1,203 @@
+package testivus
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+)
+
+// Delta is how one bucket changed between two reports.
+type Delta struct {
+	Name   string
+	Before int
+	After  int
+}
+
+// Change is the difference in count.
+func (d Delta) Change() int { return d.After - d.Before }
+
+// Percent is the change as a percentage of the count before. It is +Inf for
+// buckets that are new.
+func (d Delta) Percent() float64 {
+	if d.Before == 0 {
+		return math.Inf(1)
+	}
+	return float64(d.Change()) / float64(d.Before) * 100
+}
+
+// Added is true for buckets that were empty before.
+func (d Delta) Added() bool { return d.Before == 0 && d.After > 0 }
+
+// Removed is true for buckets that are empty now.
+func (d Delta) Removed() bool { return d.Before > 0 && d.After == 0 }
+
+// Diff is how one report differs from another. Only buckets that changed are
+// listed.
+type Diff struct {
+	Total Delta
+	Tags  []Delta
+	Tests []Delta
+}
+
+// Compare reports how b differs from a, such as a run against a previous run.
+func Compare(a, b *Report) *Diff {
+	return &Diff{
+		Total: Delta{Name: "total", Before: a.Total(), After: b.Total()},
+		Tags:  deltas(a.ByTag(), b.ByTag()),
+		Tests: deltas(a.ByName(), b.ByName()),
+	}
+}
+
+func deltas(before, after map[string]int) []Delta {
+	var ds []Delta
+	for name, c := range before {
+		if after[name] != c {
+			ds = append(ds, Delta{Name: name, Before: c, After: after[name]})
+		}
+	}
+	for name, c := range after {
+		if _, ok := before[name]; !ok {
+			ds = append(ds, Delta{Name: name, After: c})
+		}
+	}
+
+	sort.Slice(ds, func(i, j int) bool { return ds[i].Name < ds[j].Name })
+	return ds
+}
+
+// String renders the diff as text.
+func (d *Diff) String() string {
+	var buf bytes.Buffer
+	w := newTabWriter(&buf)
+	fmt.Fprintf(w, "Total: %d -> %d (%s)\n", d.Total.Before, d.Total.After, d.Total.describe())
+
+	section := func(title string, ds []Delta) {
+		if len(ds) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "\n%s:\n", title)
+		for _, delta := range ds {
+			fmt.Fprintf(w, "\t%s\t%d -> %d\t%s\n", delta.Name, delta.Before, delta.After, delta.describe())
+		}
+	}
+	section("By Tag", d.Tags)
+	section("By Test", d.Tests)
+	w.Flush()
+
+	return buf.String()
+}
+
+func (d Delta) describe() string {
+	switch {
+	case d.Change() == 0:
+		return "unchanged"
+	case d.Added():
+		return "new"
+	case d.Removed():
+		return "gone"
+	}
+	return fmt.Sprintf("%+d, %+.0f%%", d.Change(), d.Percent())
+}
+
+// Streaks counts, for every tag in any of the reports, how many of the most
+// recent reports in a row it has been clean in. Reports are given oldest
+// first, so a tag disappointing in the last report has a streak of zero.
+func Streaks(reports []*Report) map[string]int {
+	streaks := make(map[string]int)
+	for _, r := range reports {
+		for tag := range r.ByTag() {
+			streaks[tag] = 0
+		}
+	}
+
+	for tag := range streaks {
+		for i := len(reports) - 1; i >= 0 && reports[i].ByTag()[tag] == 0; i-- {
+			streaks[tag]++
+		}
+	}
+	return streaks
+}
+
+// DescribeStreaks renders the clean streaks, longest first, such as
+// "speed: clean for 5 runs." Tags that aren't clean are left out.
+func DescribeStreaks(streaks map[string]int) string {
+	var rows []reportRow
+	for tag, n := range streaks {
+		if n > 0 {
+			rows = append(rows, reportRow{ID: tag, Count: n})
+		}
+	}
+	sortRows(rows)
+
+	var buf bytes.Buffer
+	for _, r := range rows {
+		runs := "runs"
+		if r.Count == 1 {
+			runs = "run"
+		}
+		fmt.Fprintf(&buf, "%s: clean for %d %s.\n", r.ID, r.Count, runs)
+	}
+	return buf.String()
+}
+
+// ByTimeBucket counts the grievances in the reports by when in the week they
+// were registered, to show whether disappointments cluster at certain times,
+// such as during nightly infrastructure load. The week is cut into buckets of
+// the given size, counted from midnight on Sunday, and each is keyed by the
+// day and time it starts at, such as "Tue 02:00". Times are taken in the zone
+// they were recorded in, and grievances without a time are left out. A bucket
+// of zero or less means an hour.
+func ByTimeBucket(reports []*Report, bucket time.Duration) map[string]int {
+	if bucket <= 0 {
+		bucket = time.Hour
+	}
+
+	counts := make(map[string]int)
+	for _, r := range reports {
+		for _, v := range r.d.Grievances {
+			for _, g := range v {
+				if g.Time.IsZero() {
+					continue
+				}
+				counts[timeBucket(g.Time, bucket)]++
+			}
+		}
+	}
+	return counts
+}
+
+// timeBucket is the key of the bucket t falls in. It goes by the wall clock so
+// daylight saving changes don't shift the buckets.
+func timeBucket(t time.Time, bucket time.Duration) string {
+	h, m, s := t.Clock()
+	since := time.Duration(t.Weekday())*24*time.Hour +
+		time.Duration(h)*time.Hour + time.Duration(m)*time.Minute + time.Duration(s)*time.Second
+
+	// any Sunday will do, it only anchors the day names
+	sunday := time.Date(2000, time.January, 2, 0, 0, 0, 0, time.UTC)
+	return sunday.Add(since.Truncate(bucket)).Format("Mon 15:04")
+}
+
+// DescribeTimeBuckets renders the counts from ByTimeBucket, busiest first, such
+// as "Tue 02:00: 14 grievances."
+func DescribeTimeBuckets(buckets map[string]int) string {
+	var rows []reportRow
+	for key, n := range buckets {
+		if n > 0 {
+			rows = append(rows, reportRow{ID: key, Count: n})
+		}
+	}
+	sortRows(rows)
+
+	var buf bytes.Buffer
+	for _, r := range rows {
+		grievances := "grievances"
+		if r.Count == 1 {
+			grievances = "grievance"
+		}
+		fmt.Fprintf(&buf, "%s: %d %s.\n", r.ID, r.Count, grievances)
+	}
+	return buf.String()
+}
@@ -0,0 +1,252 @@
+package testivus
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+	"text/template"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Config gathers testivus' options in one place, as an alternative to calling
+// the Set functions one by one. Zero fields leave the option as it is, so a
+// Config can be combined with Set calls and flags.
+type Config struct {
+	// Format is the default for -testivus.format. The flag still wins when it
+	// is given, even if the flags were parsed before the Config is applied.
+	Format string
+	// Template replaces the report, as with SetTemplate.
+	Template *template.Template
+	// Footer closes the text report, as with SetFooter.
+	Footer func(*Report) string
+	// ExitPolicy decides the exit code, as with SetExitPolicy.
+	ExitPolicy func(*Report) int
+	// Store keeps the grievances, as with SetStore.
+	Store Store
+	// Commit attributes the run to a commit, as with SetCommit.
+	Commit string
+	// Seed seeds sampling, as with SetSeed. -testivus.seed still wins when it
+	// is given.
+	Seed int64
+
+	// PerTestLimit caps the grievances stored per test, as with
+	// SetPerTestLimit.
+	PerTestLimit int
+	// PerTestBudget fails the run when a test has more grievances, as with
+	// SetPerTestBudget.
+	PerTestBudget int
+	// MinSeverity suppresses less severe grievances, as with SetMinSeverity.
+	MinSeverity Severity
+	// SeveritySampling samples the report file by severity, as with
+	// SetSeveritySampling.
+	SeveritySampling map[Severity]float64
+	// LatencyBuckets tag grievances by duration, as with SetLatencyBuckets.
+	LatencyBuckets []time.Duration
+	// Escalations raise the severity of busy tags, as with SetEscalation.
+	Escalations []Escalation
+	// ErrorSeverities infer severities from errors, in order, as with
+	// RegisterErrorSeverity.
+	ErrorSeverities []ErrorSeverity
+	// SeverityClassifier sets the severity of new grievances, as with
+	// SetSeverityClassifier.
+	SeverityClassifier func(Disappointment) Severity
+	// RecentWindow keeps the last grievances for Recent, as with
+	// SetRecentWindow.
+	RecentWindow int
+
+	// SeverityWeights set how much each severity adds to the score, as with
+	// SetSeverityWeight.
+	SeverityWeights map[Severity]int
+	// TestWeights scale the score of matching tests, in order, as with
+	// SetTestWeight.
+	TestWeights []TestWeight
+	// ScoreTarget is the score to stay under, as with SetScoreTarget.
+	ScoreTarget int
+	// TagLinks link tags to dashboards, as with SetTagLinks.
+	TagLinks map[string]string
+	// DedupKey decides which grievances are duplicates, as with SetDedupKey.
+	DedupKey func(Disappointment) string
+	// RegexDimensions group grievances by part of their messages, in order,
+	// as with AddRegexDimension.
+	RegexDimensions []RegexDimension
+	// ErrorNormalizers rewrite errors before they are counted, in order, as
+	// with SetErrorNormalizer.
+	ErrorNormalizers []ErrorNormalizer
+	// DurationUnit and DurationPrecision render durations, as with
+	// SetDurationFormat.
+	DurationUnit      time.Duration
+	DurationPrecision int
+	// JSONFieldNames rename the fields of the JSON report, as with
+	// SetJSONFieldNames.
+	JSONFieldNames map[string]string
+
+	// FailFastPerTest and FailFastSkip stop a test at its first grievance, as
+	// with SetFailFastPerTest and SetFailFastSkip.
+	FailFastPerTest bool
+	FailFastSkip    bool
+	// Hooks are called for every grievance, as with OnGrievance.
+	Hooks []func(Disappointment)
+}
+
+// TestWeight weighs the tests matching Pattern by Weight in the score.
+type TestWeight struct {
+	Pattern string
+	Weight  float64
+}
+
+// Escalation raises grievances tagged Tag to To once there are more than
+// Count of them.
+type Escalation struct {
+	Tag   string
+	Count int
+	To    Severity
+}
+
+// ErrorSeverity infers Severity for grievances whose error matches Target.
+type ErrorSeverity struct {
+	Target   error
+	Severity Severity
+}
+
+// RegexDimension groups grievances as Name by the first capture group of
+// Pattern.
+type RegexDimension struct {
+	Name    string
+	Pattern string
+}
+
+// ErrorNormalizer rewrites the part of errors matching Pattern to
+// Replacement.
+type ErrorNormalizer struct {
+	Pattern     string
+	Replacement string
+}
+
+// apply sets every option that isn't zero.
+func (c Config) apply() error {
+	if c.Format != "" && !flagGiven("testivus.format") {
+		*format = c.Format
+	}
+	if c.Template != nil {
+		SetTemplate(c.Template)
+	}
+	if c.Footer != nil {
+		SetFooter(c.Footer)
+	}
+	if c.ExitPolicy != nil {
+		SetExitPolicy(c.ExitPolicy)
+	}
+	if c.Store != nil {
+		SetStore(c.Store)
+	}
+	if c.Commit != "" {
+		SetCommit(c.Commit)
+	}
+	if c.Seed != 0 {
+		SetSeed(c.Seed)
+	}
+
+	if c.PerTestLimit != 0 {
+		SetPerTestLimit(c.PerTestLimit)
+	}
+	if c.PerTestBudget != 0 {
+		SetPerTestBudget(c.PerTestBudget)
+	}
+	if c.MinSeverity != 0 {
+		SetMinSeverity(c.MinSeverity)
+	}
+	if c.SeveritySampling != nil {
+		SetSeveritySampling(c.SeveritySampling)
+	}
+	if c.LatencyBuckets != nil {
+		SetLatencyBuckets(c.LatencyBuckets)
+	}
+	for _, e := range c.Escalations {
+		SetEscalation(e.Tag, e.Count, e.To)
+	}
+	for _, es := range c.ErrorSeverities {
+		RegisterErrorSeverity(es.Target, es.Severity)
+	}
+	if c.SeverityClassifier != nil {
+		SetSeverityClassifier(c.SeverityClassifier)
+	}
+	if c.RecentWindow != 0 {
+		SetRecentWindow(c.RecentWindow)
+	}
+
+	for s, w := range c.SeverityWeights {
+		SetSeverityWeight(s, w)
+	}
+	for _, tw := range c.TestWeights {
+		if err := SetTestWeight(tw.Pattern, tw.Weight); err != nil {
+			return err
+		}
+	}
+	if c.ScoreTarget != 0 {
+		SetScoreTarget(c.ScoreTarget)
+	}
+	if c.TagLinks != nil {
+		if err := SetTagLinks(c.TagLinks); err != nil {
+			return err
+		}
+	}
+	if c.DedupKey != nil {
+		SetDedupKey(c.DedupKey)
+	}
+	for _, rd := range c.RegexDimensions {
+		if err := AddRegexDimension(rd.Name, rd.Pattern); err != nil {
+			return err
+		}
+	}
+	for _, en := range c.ErrorNormalizers {
+		if err := SetErrorNormalizer(en.Pattern, en.Replacement); err != nil {
+			return err
+		}
+	}
+	if c.DurationUnit != 0 {
+		if err := SetDurationFormat(c.DurationUnit, c.DurationPrecision); err != nil {
+			return err
+		}
+	}
+	if c.JSONFieldNames != nil {
+		SetJSONFieldNames(c.JSONFieldNames)
+	}
+
+	if c.FailFastPerTest {
+		SetFailFastPerTest(true)
+	}
+	if c.FailFastSkip {
+		SetFailFastSkip(true)
+	}
+	for _, h := range c.Hooks {
+		OnGrievance(h)
+	}
+	return nil
+}
+
+// flagGiven reports whether the flag name was given on the command line.
+func flagGiven(name string) bool {
+	given := false
+	flag.Visit(func(f *flag.Flag) {
+		given = given || f.Name == name
+	})
+	return given
+}
+
+// RunWithConfig is Run with the options in cfg applied first.
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(testivus.RunWithConfig(m, testivus.Config{
+//			PerTestBudget: 3,
+//			MinSeverity:   testivus.Minor,
+//		}))
+//	}
+func RunWithConfig(m *testing.M, cfg Config) int {
+	if err := cfg.apply(); err != nil {
+		fmt.Println(errors.Wrap(err, "invalid config"))
+		return 1
+	}
+	return run(m)
+}
@@ -0,0 +1,21 @@
+package testivus
+
+// dedupKeyFunc collapses grievances that count as duplicates of each other.
+var dedupKeyFunc func(Disappointment) string
+
+// SetDedupKey sets how grievances are collapsed when counting duplicates. By
+// default grievances are duplicates when their Key matches, meaning the same
+// test name, message, tags and error. Passing nil restores the default.
+//
+//	// collapse by message and tags, whichever test registered it
+//	testivus.SetDedupKey(func(d testivus.Disappointment) string { return d.String() })
+func SetDedupKey(key func(Disappointment) string) {
+	dedupKeyFunc = key
+}
+
+func dedupKey(g *disappointment) string {
+	if dedupKeyFunc != nil {
+		return dedupKeyFunc(g)
+	}
+	return g.Key()
+}
@@ -0,0 +1,35 @@
+package testivus
+
+import (
+	"fmt"
+	"regexp"
+)
+
+type dimension struct {
+	name string
+	re   *regexp.Regexp
+}
+
+type dimensionRows struct {
+	Name string
+	Rows []reportRow
+}
+
+var dimensions []dimension
+
+// AddRegexDimension groups grievances by a value pulled out of their messages.
+// The first capture group of pattern becomes the bucket, so
+// `call to (\S+)` groups "slow call to /v1/users (430ms)" under /v1/users.
+// Messages that don't match are left out of the dimension.
+func AddRegexDimension(name, pattern string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return err
+	}
+	if re.NumSubexp() < 1 {
+		return fmt.Errorf("dimension %s: pattern %q has no capture group", name, pattern)
+	}
+
+	dimensions = append(dimensions, dimension{name: name, re: re})
+	return nil
+}
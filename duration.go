@@ -0,0 +1,81 @@
+package testivus
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// durationUnits are the units SetDurationFormat accepts, by their suffix.
+var durationUnits = map[time.Duration]string{
+	time.Nanosecond:  "ns",
+	time.Microsecond: "µs",
+	time.Millisecond: "ms",
+	time.Second:      "s",
+	time.Minute:      "m",
+	time.Hour:        "h",
+}
+
+var (
+	durationUnit      time.Duration
+	durationPrecision int
+)
+
+// SetDurationFormat renders durations in the given unit with precision
+// decimals, such as "12.35ms" for time.Millisecond and 2. It applies to the
+// durations and latencies in the JSON report, which become strings in that
+// format instead of nanoseconds, to the relative timeline and to the duration
+// template function. By default durations are rendered as time.Duration does.
+// A unit of zero restores the default. The unit must be one of
+// time.Nanosecond, Microsecond, Millisecond, Second, Minute or Hour, so the
+// rendered durations can be read back; any other unit is an error.
+func SetDurationFormat(unit time.Duration, precision int) error {
+	if _, ok := durationUnits[unit]; unit != 0 && !ok {
+		return fmt.Errorf("unsupported duration unit %v: use a unit such as time.Millisecond", unit)
+	}
+	if precision < 0 {
+		precision = 0
+	}
+	durationUnit, durationPrecision = unit, precision
+	return nil
+}
+
+// formatDuration renders d as set by SetDurationFormat.
+func formatDuration(d time.Duration) string {
+	if durationUnit == 0 {
+		return d.String()
+	}
+	v := float64(d) / float64(durationUnit)
+	return strconv.FormatFloat(v, 'f', durationPrecision, 64) + durationUnits[durationUnit]
+}
+
+// jsonDuration is a duration in the JSON report: nanoseconds by default, or a
+// string as set by SetDurationFormat. Both are read back.
+type jsonDuration time.Duration
+
+func (d jsonDuration) MarshalJSON() ([]byte, error) {
+	if durationUnit == 0 {
+		return json.Marshal(int64(d))
+	}
+	return json.Marshal(formatDuration(time.Duration(d)))
+}
+
+func (d *jsonDuration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		var n int64
+		if err := json.Unmarshal(b, &n); err != nil {
+			return err
+		}
+		*d = jsonDuration(n)
+		return nil
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = jsonDuration(v)
+	return nil
+}
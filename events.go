@@ -0,0 +1,50 @@
+package testivus
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// eventLog writes -testivus.events, one JSON object per line.
+type eventLog struct {
+	f   *os.File
+	enc *json.Encoder
+}
+
+var events *eventLog
+
+// openEvents starts the event log at path.
+func openEvents(path string) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	events = &eventLog{f: f, enc: json.NewEncoder(f)}
+	return nil
+}
+
+// emit writes an event with its name and time, plus fields. Events are only
+// written during Run, under the lock or before the tests start, so they never
+// interleave. Errors writing events are ignored so they can't fail the run;
+// the final report is what counts.
+func emit(event string, fields map[string]interface{}) {
+	if events == nil {
+		return
+	}
+
+	e := map[string]interface{}{"event": event, "time": now().Format(time.RFC3339Nano)}
+	for k, v := range fields {
+		e[k] = v
+	}
+	events.enc.Encode(e)
+}
+
+// closeEvents ends the event log.
+func closeEvents() {
+	if events == nil {
+		return
+	}
+	events.f.Close()
+	events = nil
+}
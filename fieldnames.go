@@ -0,0 +1,40 @@
+package testivus
+
+import "encoding/json"
+
+var jsonFieldNames map[string]string
+
+// SetJSONFieldNames renames the fields of each grievance in the JSON report,
+// for pipelines that expect other keys. The map goes from the default name,
+// such as "message", "tags", "error" or "testName", to the name to use.
+// Reports are read back with the same names. Passing nil restores the
+// defaults.
+func SetJSONFieldNames(names map[string]string) {
+	jsonFieldNames = names
+}
+
+// jsonFieldOriginals maps the renamed fields back to their default names.
+func jsonFieldOriginals() map[string]string {
+	originals := make(map[string]string, len(jsonFieldNames))
+	for from, to := range jsonFieldNames {
+		originals[to] = from
+	}
+	return originals
+}
+
+// renameFields renames the keys of the JSON object b.
+func renameFields(b []byte, names map[string]string) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(b, &fields); err != nil {
+		return nil, err
+	}
+
+	renamed := make(map[string]json.RawMessage, len(fields))
+	for k, v := range fields {
+		if n, ok := names[k]; ok {
+			k = n
+		}
+		renamed[k] = v
+	}
+	return json.Marshal(renamed)
+}
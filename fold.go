@@ -0,0 +1,63 @@
+package testivus
+
+import "strings"
+
+// foldTag is the canonical form of a tag when folding case.
+func foldTag(t string) string {
+	return strings.ToLower(strings.TrimSpace(t))
+}
+
+// foldedTagNames maps every tag, and every folded form, to the spelling shown
+// for it: the most common original spelling, breaking ties alphabetically.
+func foldedTagNames(d *disappointments) map[string]string {
+	spellings := make(map[string]map[string]int)
+	for _, v := range d.Grievances {
+		for _, g := range v {
+			for _, t := range g.Tags {
+				pairCount(spellings, foldTag(t), t)
+			}
+		}
+	}
+
+	names := make(map[string]string)
+	for folded, counts := range spellings {
+		rep, _ := top(counts)
+		for t := range counts {
+			names[t] = rep
+		}
+		names[folded] = rep
+	}
+	return names
+}
+
+// tagName is the tag as it is counted in the summary, for options keyed by
+// tag such as SetEscalation and SetTagLinks, so they match every spelling
+// when -testivus.foldcase is on.
+func (s summary) tagName(tag string) string {
+	if s.tagNames == nil {
+		return tag
+	}
+	if name, ok := s.tagNames[foldTag(tag)]; ok {
+		return name
+	}
+	return tag
+}
+
+// tags are the tags of g as they are counted in the summary, folded when
+// -testivus.foldcase is on.
+func (s summary) tags(g *disappointment) []string {
+	if s.tagNames == nil {
+		return g.Tags
+	}
+
+	var tags []string
+	seen := make(map[string]bool)
+	for _, t := range g.Tags {
+		name := s.tagNames[t]
+		if !seen[name] {
+			seen[name] = true
+			tags = append(tags, name)
+		}
+	}
+	return tags
+}
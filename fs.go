@@ -0,0 +1,98 @@
+package testivus
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"sync"
+	"testing"
+)
+
+// WriteFileFS is a file system that can also write files. The file system
+// AssertMaxFSTouches hands to fn always implements it, so writes are counted
+// too; they fail with errors.ErrUnsupported if the underlying file system
+// can't write.
+type WriteFileFS interface {
+	fs.FS
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+}
+
+// FSTouches counts the file system operations made by the code under test.
+type FSTouches struct {
+	Reads  int
+	Writes int
+}
+
+// Total is the number of operations of any kind.
+func (t FSTouches) Total() int { return t.Reads + t.Writes }
+
+// AssertMaxFSTouches runs fn against fsys, counting every read (Open,
+// ReadFile, ReadDir and Stat) and every write (WriteFile). If there were more
+// than max, it registers a grievance tagged fs with the counts as fields and
+// fails t. It returns the counts for further assertions.
+func AssertMaxFSTouches(t testing.TB, fsys fs.FS, max int, fn func(fs.FS)) FSTouches {
+	t.Helper()
+
+	c := &countingFS{base: fsys}
+	fn(c)
+
+	touches := c.touches()
+	if touches.Total() > max {
+		Failure(t, fmt.Sprintf("touched the file system %d times, max %d", touches.Total(), max), "fs").
+			WithField("reads", touches.Reads).
+			WithField("writes", touches.Writes)
+	}
+	return touches
+}
+
+// countingFS counts the operations made through it on its base file system.
+type countingFS struct {
+	base fs.FS
+
+	mu sync.Mutex
+	n  FSTouches
+}
+
+func (c *countingFS) read() {
+	c.mu.Lock()
+	c.n.Reads++
+	c.mu.Unlock()
+}
+
+func (c *countingFS) touches() FSTouches {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n
+}
+
+func (c *countingFS) Open(name string) (fs.File, error) {
+	c.read()
+	return c.base.Open(name)
+}
+
+func (c *countingFS) ReadFile(name string) ([]byte, error) {
+	c.read()
+	return fs.ReadFile(c.base, name)
+}
+
+func (c *countingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	c.read()
+	return fs.ReadDir(c.base, name)
+}
+
+func (c *countingFS) Stat(name string) (fs.FileInfo, error) {
+	c.read()
+	return fs.Stat(c.base, name)
+}
+
+func (c *countingFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	c.mu.Lock()
+	c.n.Writes++
+	c.mu.Unlock()
+
+	w, ok := c.base.(WriteFileFS)
+	if !ok {
+		return &fs.PathError{Op: "write", Path: name, Err: errors.ErrUnsupported}
+	}
+	return w.WriteFile(name, data, perm)
+}
@@ -0,0 +1,14 @@
+package testivus
+
+import "strings"
+
+// fuzzTarget is the fuzz target a test belongs to, or "" if it isn't a fuzz
+// input. Each input of a fuzz target runs as a subtest of it, named after the
+// corpus entry, such as FuzzParse/seed#0.
+func fuzzTarget(name string) string {
+	i := strings.Index(name, "/")
+	if i < 0 || !strings.HasPrefix(name, "Fuzz") {
+		return ""
+	}
+	return name[:i]
+}
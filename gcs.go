@@ -0,0 +1,37 @@
+//go:build gcs
+
+package testivus
+
+import (
+	"context"
+
+	"cloud.google.com/go/storage"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	uploaders["gs"] = uploadGCS
+}
+
+// uploadGCS writes a report to a gs://bucket/object URL, with Application
+// Default Credentials. Build with -tags gcs.
+func uploadGCS(url string, data []byte) error {
+	bucket, key, err := splitBucketURL(url)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	client, err := storage.NewClient(ctx)
+	if err != nil {
+		return errors.Wrap(err, "create GCS client")
+	}
+	defer client.Close()
+
+	w := client.Bucket(bucket).Object(key).NewWriter(ctx)
+	if _, err := w.Write(data); err != nil {
+		w.Close()
+		return errors.Wrap(err, "upload to GCS")
+	}
+	return errors.Wrap(w.Close(), "upload to GCS")
+}
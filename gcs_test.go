@@ -0,0 +1,36 @@
+//go:build gcs
+
+package testivus
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestUploadGCS(t *testing.T) {
+	var path, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		path, body = r.URL.Path, string(b)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"bucket":"reports","name":"runs/report.json"}`)
+	}))
+	defer srv.Close()
+
+	// the emulator host needs no credentials, so nothing leaves the machine
+	t.Setenv("STORAGE_EMULATOR_HOST", strings.TrimPrefix(srv.URL, "http://"))
+
+	if err := uploadGCS("gs://reports/runs/report.json", []byte(`{"total":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if path != "/upload/storage/v1/b/reports/o" || !strings.Contains(body, `{"total":1}`) {
+		t.Errorf("got %s with %q", path, body)
+	}
+
+	if err := uploadGCS("gs://reports", nil); err == nil {
+		t.Error("uploaded to a URL without a key")
+	}
+}
@@ -0,0 +1,109 @@
+package testivus
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// githubSummary writes the report as Markdown to the GitHub Actions job
+// summary and prints a warning annotation for every grievance with a source
+// location. It does nothing outside GitHub Actions. The caller must have
+// filled in the summary.
+func (d *disappointments) githubSummary() error {
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if os.Getenv("GITHUB_ACTIONS") != "true" || path == "" {
+		return nil
+	}
+
+	var names []string
+	for name := range d.Grievances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, g := range d.Grievances[name] {
+			if g.File != "" {
+				fmt.Printf("::warning file=%s,line=%d,title=%s::%s\n", githubProperty(workspaceFile(g.File)), g.Line, githubProperty(g.Name), githubData(g.String()))
+			}
+		}
+	}
+
+	// the job summary is shared by every step, so append to it
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(d.Summary.markdown()); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// workspaceFile makes a source file relative to the repository checkout, as
+// annotations expect, rather than to the package being tested.
+func workspaceFile(file string) string {
+	abs, err := filepath.Abs(file)
+	if err != nil {
+		return file
+	}
+	if rel, err := filepath.Rel(os.Getenv("GITHUB_WORKSPACE"), abs); err == nil && !strings.HasPrefix(rel, "..") {
+		return filepath.ToSlash(rel)
+	}
+	return file
+}
+
+// markdown renders the summary as Markdown tables.
+func (s summary) markdown() string {
+	var b strings.Builder
+	b.WriteString("### The airing of grievances\n\n")
+	fmt.Fprintf(&b, "I got a lot of problems with you people! (%d disappointments across %d tests)\n", s.Total, s.Tests)
+
+	table := func(column string, rows []reportRow) {
+		if len(rows) == 0 {
+			return
+		}
+		fmt.Fprintf(&b, "\n| %s | Count |\n| --- | ---: |\n", column)
+		for _, r := range rows {
+			cell := markdownCell(r.ID)
+			if r.Link != "" {
+				cell = fmt.Sprintf("[%s](%s)", markdownLinkText(cell), markdownURL(r.Link))
+			}
+			fmt.Fprintf(&b, "| %s | %d |\n", cell, r.Count)
+		}
+	}
+	table("Tag", s.tagRows)
+	table("Error", s.errorRows)
+	table("Test", s.nameRows)
+	return b.String()
+}
+
+// markdownCell escapes s for a Markdown table cell.
+func markdownCell(s string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(s)
+}
+
+// markdownLinkText escapes s for the text of a Markdown link.
+func markdownLinkText(s string) string {
+	return strings.NewReplacer("[", `\[`, "]", `\]`).Replace(s)
+}
+
+// markdownURL escapes the characters that would end a Markdown link's URL
+// early, or break the table it is in.
+func markdownURL(u string) string {
+	return strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29", "|", "%7C").Replace(u)
+}
+
+// githubData escapes the message of a workflow command.
+func githubData(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(s)
+}
+
+// githubProperty escapes a property value of a workflow command.
+func githubProperty(s string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(s)
+}
@@ -0,0 +1,102 @@
+package testivus
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	stderrors "errors"
+	"flag"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	fileFlags["gob"] = flag.String("testivus.gobfile", "", "write the report as gob, for fast loading with ParseGobReport")
+	outputWriters["gob"] = writeGob
+}
+
+// gobReport is what a gob report holds. The summary is left out since it is
+// recomputed when the report is loaded.
+type gobReport struct {
+	Commit     string
+	Failed     map[string]bool
+	Grievances map[string][]*disappointment
+}
+
+// writeGob saves the grievances to path as gob. JSON stays the format to share
+// reports in; gob is for loading large reports back into Go quickly.
+func writeGob(path string, d *disappointments) error {
+	return writeFile(path, func(w io.Writer) error {
+		return gob.NewEncoder(w).Encode(gobReport{Commit: d.Commit, Failed: d.Failed, Grievances: d.Grievances})
+	})
+}
+
+// ParseGobReport reads a gob report, as written by -testivus.gobfile. Like
+// ParseReport, parsed reports have an exit code of 0.
+func ParseGobReport(r io.Reader) (*Report, error) {
+	var saved gobReport
+	if err := gob.NewDecoder(r).Decode(&saved); err != nil {
+		return nil, errors.Wrap(err, "decode report")
+	}
+
+	d := newDisappointments(nil)
+	d.Commit = saved.Commit
+	for name, failed := range saved.Failed {
+		d.Failed[name] = failed
+	}
+	for name, v := range saved.Grievances {
+		d.Grievances[name] = v
+	}
+	return newReport(d, 0), nil
+}
+
+// gobDisappointment is a disappointment as gob sees it. Gob can't encode
+// arbitrary errors or field values, so the error is kept as its message and
+// the fields as JSON.
+type gobDisappointment struct {
+	Plain  plainDisappointment
+	Error  *string
+	Fields []byte
+}
+
+type plainDisappointment disappointment
+
+// GobEncode renders the disappointment to gob, with its error as a string
+func (d disappointment) GobEncode() ([]byte, error) {
+	v := gobDisappointment{Plain: plainDisappointment(d)}
+	v.Plain.Error, v.Plain.Fields = nil, nil
+
+	if d.Error != nil {
+		e := d.Error.Error()
+		v.Error = &e
+	}
+	if len(d.Fields) > 0 {
+		b, err := json.Marshal(d.Fields)
+		if err != nil {
+			return nil, err
+		}
+		v.Fields = b
+	}
+
+	var buf bytes.Buffer
+	err := gob.NewEncoder(&buf).Encode(v)
+	return buf.Bytes(), err
+}
+
+// GobDecode reads a disappointment written by GobEncode
+func (d *disappointment) GobDecode(b []byte) error {
+	var v gobDisappointment
+	if err := gob.NewDecoder(bytes.NewReader(b)).Decode(&v); err != nil {
+		return err
+	}
+
+	*d = disappointment(v.Plain)
+	if v.Error != nil {
+		d.Error = stderrors.New(*v.Error)
+	}
+	if len(v.Fields) > 0 {
+		return json.Unmarshal(v.Fields, &d.Fields)
+	}
+	return nil
+}
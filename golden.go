@@ -0,0 +1,76 @@
+package testivus
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+)
+
+// goldenVersion is bumped whenever the golden format changes.
+const goldenVersion = 2
+
+// golden renders the disappointments in a stable format meant for comparing
+// against checked in golden files. Everything is sorted and nothing depends on
+// timing, verbosity or the environment.
+func (d *disappointments) golden() string {
+	d.Lock()
+	defer d.Unlock()
+
+	s := d.summarize()
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "testivus golden v%d\n", goldenVersion)
+	fmt.Fprintf(&buf, "total: %d\n", s.Total)
+
+	section := func(title string, rows []reportRow) {
+		if len(rows) == 0 {
+			return
+		}
+		fmt.Fprintf(&buf, "\n%s:\n", title)
+		for _, r := range rows {
+			fmt.Fprintf(&buf, "  %s: %d\n", r.ID, r.Count)
+		}
+	}
+
+	var severityRows []reportRow
+	for sev := Critical; sev >= Info; sev-- {
+		if c := s.BySeverity[sev.String()]; c > 0 {
+			severityRows = append(severityRows, reportRow{ID: sev.String(), Count: c})
+		}
+	}
+
+	section("by tag", s.tagRows)
+	section("tags together", s.pairRows)
+	section("by error", s.errorRows)
+	for _, dr := range s.dimensionRows {
+		section("by "+dr.Name, dr.Rows)
+	}
+	section("by severity", severityRows)
+	if len(s.escalationRows) > 0 {
+		fmt.Fprintf(&buf, "\nescalated:\n")
+		for _, e := range s.escalationRows {
+			fmt.Fprintf(&buf, "  %s: %d > %d, now %s\n", e.Tag, e.Count, e.Limit, e.Severity)
+		}
+	}
+	section("by file", s.fileRows)
+	section("by package", s.packageRows)
+	section("by fuzz target", s.fuzzRows)
+	section("by test", s.nameRows)
+
+	var names []string
+	for name := range d.Grievances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if len(names) > 0 {
+		fmt.Fprintf(&buf, "\ngrievances:\n")
+	}
+	for _, name := range names {
+		for _, g := range d.Grievances[name] {
+			fmt.Fprintf(&buf, "  %s [%s] %s\n", name, s.severityOf(g), g)
+		}
+	}
+
+	return buf.String()
+}
@@ -0,0 +1,49 @@
+package testivus
+
+import (
+	"crypto/sha256"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "update golden files")
+
+// goldenFixtures are the SHA-256 sums of testdata/report.golden by
+// goldenVersion. A change to the fixture needs a new version, so golden files
+// checked in by users are never compared against a different format under the
+// same version.
+var goldenFixtures = map[int]string{
+	1: "4a3b45153c667f28eb47b55400385235c81621903241a0d108862375c6894537",
+	2: "f46bd29459e6b9f1a13a2050b2bf58b646fe4168660c67afb8edb9acfc06526f",
+}
+
+func TestGolden(t *testing.T) {
+	d := newDisappointments(nil)
+	d.record(nil, "TestB", "You're slow!", []string{"speed"}).WithError(errors.New("timeout exceeded"))
+	d.record(nil, "TestB", "You're send too much data!", []string{"speed", "download"})
+	d.record(nil, "TestA", "My son tells me your company stinks!", nil).WithSeverity(Critical)
+	d.record(nil, "TestA", "I find tinsel distracting.", []string{"download"}).WithSeverity(Info)
+
+	got := d.golden()
+	path := filepath.Join("testdata", "report.golden")
+	if *update {
+		if err := os.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("golden report mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+	if sum := fmt.Sprintf("%x", sha256.Sum256(want)); goldenFixtures[goldenVersion] != sum {
+		t.Errorf("%s changed without a goldenVersion bump: bump it and add %q to goldenFixtures", path, sum)
+	}
+}
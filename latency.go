@@ -0,0 +1,80 @@
+package testivus
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+)
+
+const latencyTagPrefix = "slow:>"
+
+var latencyBuckets []time.Duration
+
+// SetLatencyBuckets sets the thresholds used to tag grievances by duration. A
+// grievance with a duration is tagged with the largest threshold it exceeds,
+// e.g. "slow:>1s". Grievances without a duration are never tagged.
+func SetLatencyBuckets(buckets []time.Duration) {
+	b := append([]time.Duration(nil), buckets...)
+	sort.Slice(b, func(i, j int) bool { return b[i] < b[j] })
+	latencyBuckets = b
+}
+
+// latencyTag returns the tag for the slowest bucket dur exceeds, or "" if it
+// exceeds none.
+func latencyTag(dur time.Duration) string {
+	for i := len(latencyBuckets) - 1; i >= 0; i-- {
+		if dur > latencyBuckets[i] {
+			return latencyTagPrefix + latencyBuckets[i].String()
+		}
+	}
+	return ""
+}
+
+// Latency is the spread of durations recorded on a tag's grievances. In JSON
+// the durations are in nanoseconds, or as set by SetDurationFormat.
+type Latency struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+}
+
+// latencyJSON is Latency with its durations as in the JSON report.
+type latencyJSON struct {
+	P50 jsonDuration `json:"p50"`
+	P90 jsonDuration `json:"p90"`
+	P99 jsonDuration `json:"p99"`
+}
+
+// MarshalJSON renders the latency to JSON
+func (l Latency) MarshalJSON() ([]byte, error) {
+	return json.Marshal(latencyJSON{jsonDuration(l.P50), jsonDuration(l.P90), jsonDuration(l.P99)})
+}
+
+// UnmarshalJSON reads a latency from JSON
+func (l *Latency) UnmarshalJSON(b []byte) error {
+	var v latencyJSON
+	if err := unmarshal(b, &v); err != nil {
+		return err
+	}
+	*l = Latency{time.Duration(v.P50), time.Duration(v.P90), time.Duration(v.P99)}
+	return nil
+}
+
+// newLatency computes nearest-rank percentiles over durs, which it sorts.
+func newLatency(durs []time.Duration) Latency {
+	sort.Slice(durs, func(i, j int) bool { return durs[i] < durs[j] })
+	return Latency{
+		P50: percentile(durs, 50),
+		P90: percentile(durs, 90),
+		P99: percentile(durs, 99),
+	}
+}
+
+// percentile is the nearest-rank p-th percentile of the sorted durations.
+func percentile(sorted []time.Duration, p int) time.Duration {
+	rank := (p*len(sorted) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
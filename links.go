@@ -0,0 +1,49 @@
+package testivus
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+var tagLinks map[string]*template.Template
+
+// SetTagLinks attaches a link, such as to a dashboard, to each tag in the
+// report. The links are text/templates given the tag and its count, e.g.
+// "https://grafana/d/tests?var-tag={{.Tag}}". If a template doesn't parse,
+// it returns an error and leaves the links unchanged.
+func SetTagLinks(links map[string]string) error {
+	parsed := make(map[string]*template.Template, len(links))
+	for tag, link := range links {
+		tmpl, err := template.New(tag).Parse(link)
+		if err != nil {
+			return fmt.Errorf("tag link for %q: %v", tag, err)
+		}
+		parsed[tag] = tmpl
+	}
+	tagLinks = parsed
+	return nil
+}
+
+// tagLink resolves the link for tag, as counted in s, or returns "" if it has
+// none.
+func (s summary) tagLink(tag string, count int) string {
+	tmpl, ok := tagLinks[tag]
+	for key, t := range tagLinks {
+		if !ok && s.tagName(key) == tag {
+			tmpl, ok = t, true
+		}
+	}
+	if !ok {
+		return ""
+	}
+
+	var b strings.Builder
+	if err := tmpl.Execute(&b, struct {
+		Tag   string
+		Count int
+	}{tag, count}); err != nil {
+		return ""
+	}
+	return b.String()
+}
@@ -0,0 +1,37 @@
+package testivus
+
+import (
+	"fmt"
+	"regexp"
+)
+
+type errorNormalizer struct {
+	re          *regexp.Regexp
+	replacement string
+}
+
+var errorNormalizers []errorNormalizer
+
+// SetErrorNormalizer adds a rule that rewrites the part of error messages
+// matching the regular expression pattern to replacement, as with
+// regexp.ReplaceAllString, before they are counted By Error. Rules apply in
+// the order they were added, so messages that differ only in addresses or IDs
+// can share a bucket:
+//
+//	testivus.SetErrorNormalizer(`\d+\.\d+\.\d+\.\d+(:\d+)?`, "<ip>")
+func SetErrorNormalizer(pattern, replacement string) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("error normalizer: %v", err)
+	}
+	errorNormalizers = append(errorNormalizers, errorNormalizer{re: re, replacement: replacement})
+	return nil
+}
+
+// normalizeError applies the error normalizers to msg.
+func normalizeError(msg string) string {
+	for _, n := range errorNormalizers {
+		msg = n.re.ReplaceAllString(msg, n.replacement)
+	}
+	return msg
+}
@@ -0,0 +1,52 @@
+//go:build otel
+
+package testivus
+
+import (
+	"context"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GrievanceSpan registers a disappointment and records it as an event on the
+// span in ctx, so it shows up in your tracing backend next to the work that
+// caused it. The event has the grievance's message, tags, test and severity
+// as registered. An error attached later with WithError is recorded on the
+// span too. Grievances suppressed by SetMinSeverity aren't recorded. Build
+// with -tags otel.
+func GrievanceSpan(ctx context.Context, t testing.TB, msg string, tags ...string) Disappointment {
+	t.Helper()
+	g := Grievance(t, msg, tags...).(*disappointment)
+
+	span := trace.SpanFromContext(ctx)
+	if !span.IsRecording() || g.suppressed {
+		return g
+	}
+
+	sev := inferSeverity(g)
+	if downgraded(g) {
+		sev = Info
+	}
+	attrs := []attribute.KeyValue{
+		attribute.String("testivus.message", g.Message),
+		attribute.StringSlice("testivus.tags", g.Tags),
+		attribute.String("testivus.test", g.Name),
+		attribute.String("testivus.severity", sev.String()),
+	}
+	if g.Error != nil {
+		attrs = append(attrs, attribute.String("testivus.error", g.Error.Error()))
+	}
+	span.AddEvent("testivus.grievance", trace.WithAttributes(attrs...))
+
+	g.onError = func(err error) {
+		if err != nil && span.IsRecording() {
+			span.RecordError(err, trace.WithAttributes(
+				attribute.String("testivus.message", g.Message),
+				attribute.String("testivus.test", g.Name),
+			))
+		}
+	}
+	return g
+}
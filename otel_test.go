@@ -0,0 +1,50 @@
+//go:build otel
+
+package testivus
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// eventSpan records the events and errors added to it.
+type eventSpan struct {
+	trace.Span
+	attrs  map[string]attribute.Value
+	errors []error
+}
+
+func (s *eventSpan) IsRecording() bool { return true }
+
+func (s *eventSpan) AddEvent(name string, opts ...trace.EventOption) {
+	cfg := trace.NewEventConfig(opts...)
+	for _, kv := range cfg.Attributes() {
+		s.attrs[string(kv.Key)] = kv.Value
+	}
+}
+
+func (s *eventSpan) RecordError(err error, opts ...trace.EventOption) {
+	s.errors = append(s.errors, err)
+}
+
+func TestGrievanceSpan(t *testing.T) {
+	span := &eventSpan{attrs: make(map[string]attribute.Value)}
+	ctx := trace.ContextWithSpan(context.Background(), span)
+
+	timeout := errors.New("timeout exceeded")
+	GrievanceSpan(ctx, t, "You're slow!", "speed").WithSeverity(Major).WithError(timeout)
+
+	if got := span.attrs["testivus.tags"].AsStringSlice(); len(got) != 1 || got[0] != "speed" {
+		t.Errorf("tags = %v", got)
+	}
+	if got := span.attrs["testivus.test"].AsString(); got != t.Name() {
+		t.Errorf("test = %q", got)
+	}
+	if len(span.errors) != 1 || span.errors[0] != timeout {
+		t.Errorf("errors = %v", span.errors)
+	}
+}
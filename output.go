@@ -0,0 +1,203 @@
+package testivus
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// outputWriters save the disappointments to a file in a named format.
+var outputWriters = map[string]func(path string, d *disappointments) error{
+	"json":   writeSampledJSON,
+	"text":   writeText,
+	"golden": writeGolden,
+}
+
+// formats render the report printed at the end of the run.
+var formats = map[string]func(d *disappointments) string{
+	"text":         (*disappointments).String,
+	"compact":      (*disappointments).compact,
+	"severity-tag": (*disappointments).severityTag,
+	"boxed":        (*disappointments).boxed,
+}
+
+// compact renders one tag=count line per tag, most disappointing first, for
+// scraping from logs. A run without tags prints its total instead.
+func (d *disappointments) compact() string {
+	d.Lock()
+	defer d.Unlock()
+
+	s := d.summarize()
+	if len(s.tagRows) == 0 {
+		return fmt.Sprintf("total=%d\n", s.Total)
+	}
+
+	var b strings.Builder
+	for _, r := range s.tagRows {
+		fmt.Fprintf(&b, "%s=%d\n", r.ID, r.Count)
+	}
+	return b.String()
+}
+
+// fileFlags are flags that name a file to write one output format to, as a
+// shorthand for adding it to -testivus.outputs. Optional formats register
+// theirs when they are built in.
+var fileFlags = map[string]*string{}
+
+// severityTag renders the grievances grouped by severity, most severe first,
+// and within each severity by tag, so the most serious problems are read by
+// category first.
+func (d *disappointments) severityTag() string {
+	d.Lock()
+	defer d.Unlock()
+
+	s := d.summarize()
+	byTag := make(map[Severity]map[string]int)
+	for _, v := range d.Grievances {
+		for _, g := range v {
+			sev := s.severityOf(g)
+			if byTag[sev] == nil {
+				byTag[sev] = make(map[string]int)
+			}
+			tags := s.tags(g)
+			if len(tags) == 0 {
+				tags = []string{"(untagged)"}
+			}
+			for _, t := range tags {
+				byTag[sev][t]++
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	w := newTabWriter(&buf)
+	fmt.Fprintf(w, "I got a lot of problems with you people! (%d disappointments)\n", s.Total)
+	for sev := Critical; sev >= Info; sev-- {
+		c := s.BySeverity[sev.String()]
+		if c == 0 {
+			continue
+		}
+
+		var rows []reportRow
+		for t, n := range byTag[sev] {
+			rows = append(rows, reportRow{ID: t, Count: n})
+		}
+		sortRows(rows)
+
+		fmt.Fprintf(w, "\n%s (%d):\n", sev, c)
+		for _, r := range rows {
+			fmt.Fprintf(w, "\t%s\t%d\t%s\n", r.ID, r.Count, strings.Repeat("|", r.Count))
+		}
+	}
+	w.Flush()
+
+	return buf.String()
+}
+
+type output struct {
+	format string
+	path   string
+}
+
+// parseOutputs reads a comma separated list of format:path pairs.
+func parseOutputs(spec string) ([]output, error) {
+	if spec == "" {
+		return nil, nil
+	}
+
+	var outs []output
+	for _, part := range strings.Split(spec, ",") {
+		i := strings.Index(part, ":")
+		if i < 1 || i == len(part)-1 {
+			return nil, fmt.Errorf("expected format:path, got %q", part)
+		}
+
+		format, path := part[:i], part[i+1:]
+		if _, ok := outputWriters[format]; !ok {
+			return nil, fmt.Errorf("unknown output format %q (known formats: %s)", format, strings.Join(outputFormats(), ", "))
+		}
+		outs = append(outs, output{format: format, path: path})
+	}
+	return outs, nil
+}
+
+func outputFormats() []string {
+	var formats []string
+	for f := range outputWriters {
+		formats = append(formats, f)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// writeSampledJSON saves the JSON report to path, sampled by severity and
+// trimmed to -testivus.maxfilesize.
+func writeSampledJSON(path string, d *disappointments) error {
+	f, err := d.sample().fit(int(*maxFileSize * (1 << 20)))
+	if err != nil {
+		return err
+	}
+	return writeJSON(path, f)
+}
+
+// writeText saves the text report to path.
+func writeText(path string, d *disappointments) error {
+	return writeFile(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, d.String())
+		return err
+	})
+}
+
+// writeGolden saves the golden report to path.
+func writeGolden(path string, d *disappointments) error {
+	return writeFile(path, func(w io.Writer) error {
+		_, err := io.WriteString(w, d.golden())
+		return err
+	})
+}
+
+// writeFile writes a report to a temporary file next to path and renames it
+// into place once write succeeds, so a failed write never leaves a partial
+// report behind. Paths like s3://bucket/key.json are uploaded instead.
+func writeFile(path string, write func(w io.Writer) error) error {
+	if scheme, ok := uploadScheme(path); ok {
+		return upload(scheme, path, write)
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+
+	if err := write(out); err != nil {
+		return err
+	}
+	if err := out.Chmod(0600); err != nil {
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	return os.Rename(out.Name(), path)
+}
+
+// writeFailed airs a failure to write one of the reports as a grievance of
+// testivus itself. It goes straight into the grievances being reported, which
+// a custom store has already been collected into. The caller must hold the
+// lock.
+func (d *disappointments) writeFailed(err error) {
+	g := newGrievance("testivus", "could not write report", []string{"testivus"})
+	g.Error = err
+	g.Severity = Major
+	d.Grievances[g.Name] = append(d.Grievances[g.Name], g)
+	d.stored[g.Name]++
+}
@@ -0,0 +1,32 @@
+package testivus
+
+import "testing"
+
+// parallelTag is the tag of grievances registered by parallel tests.
+const parallelTag = "parallel"
+
+// MarkParallel calls t.Parallel and remembers that t runs in parallel, so the
+// grievances it registers are tagged parallel. The testing package doesn't
+// say whether a test is parallel, so use it in place of t.Parallel to have
+// the report correlate disappointments with parallelism.
+func MarkParallel(t *testing.T) {
+	running.Lock()
+	running.parallel[t.Name()] = true
+	running.Unlock()
+
+	t.Parallel()
+}
+
+// tagParallel tags g if its test was marked parallel. The caller must hold the
+// lock.
+func (d *disappointments) tagParallel(g *disappointment) {
+	if !d.parallel[g.Name] {
+		return
+	}
+	for _, t := range g.Tags {
+		if t == parallelTag {
+			return
+		}
+	}
+	g.Tags = append(g.Tags, parallelTag)
+}
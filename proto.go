@@ -0,0 +1,301 @@
+//go:build proto
+
+package testivus
+
+import (
+	"encoding/json"
+	stderrors "errors"
+	"flag"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func init() {
+	fileFlags["proto"] = flag.String("testivus.protofile", "", "write the report as protobuf, as described by report.proto")
+	outputWriters["proto"] = writeProto
+}
+
+// writeProto saves the report to path in the protobuf form described by
+// report.proto. Build with -tags proto.
+func writeProto(path string, d *disappointments) error {
+	return writeFile(path, func(w io.Writer) error {
+		_, err := w.Write(d.appendProto(nil))
+		return err
+	})
+}
+
+// appendProto encodes the disappointments as a report.proto Report.
+func (d *disappointments) appendProto(b []byte) []byte {
+	b = appendProtoString(b, 1, d.Commit)
+
+	var names []string
+	for name := range d.Grievances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, g := range d.Grievances[name] {
+			b = protowire.AppendTag(b, 2, protowire.BytesType)
+			b = protowire.AppendBytes(b, g.appendProto(nil))
+		}
+	}
+
+	var tests []string
+	for name := range d.Failed {
+		tests = append(tests, name)
+	}
+	sort.Strings(tests)
+	for _, name := range tests {
+		var entry []byte
+		entry = appendProtoString(entry, 1, name)
+		entry = appendProtoVarint(entry, 2, protowire.EncodeBool(d.Failed[name]))
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+
+	if !d.Start.IsZero() {
+		b = appendProtoVarint(b, 4, uint64(d.Start.UnixNano()))
+	}
+	b = appendProtoVarint(b, 5, uint64(d.Seed))
+	return b
+}
+
+// appendProto encodes the disappointment as a report.proto Grievance.
+func (d *disappointment) appendProto(b []byte) []byte {
+	b = appendProtoString(b, 1, d.Name)
+	b = appendProtoString(b, 2, d.Message)
+	for _, t := range d.Tags {
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendString(b, t)
+	}
+	if d.Error != nil {
+		b = appendProtoString(b, 4, d.Error.Error())
+	}
+	b = appendProtoVarint(b, 5, uint64(d.Severity))
+	b = appendProtoString(b, 6, d.Artifact)
+	b = appendProtoVarint(b, 7, uint64(d.Duration))
+	b = appendProtoString(b, 8, d.File)
+	b = appendProtoVarint(b, 9, uint64(d.Line))
+	b = appendProtoString(b, 10, d.Package)
+	b = appendProtoVarint(b, 11, uint64(d.Occurrences))
+	b = appendProtoString(b, 12, d.UID)
+	if !d.Time.IsZero() {
+		b = appendProtoVarint(b, 13, uint64(d.Time.UnixNano()))
+	}
+	if !d.Deadline.IsZero() {
+		b = appendProtoVarint(b, 14, uint64(d.Deadline.UnixNano()))
+	}
+
+	var keys []string
+	for key := range d.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		// values are JSON, as in the JSON report; ones JSON can't encode
+		// are kept as their text
+		v, err := json.Marshal(d.Fields[key])
+		if err != nil {
+			v, _ = json.Marshal(fmt.Sprint(d.Fields[key]))
+		}
+		var entry []byte
+		entry = appendProtoString(entry, 1, key)
+		entry = appendProtoString(entry, 2, string(v))
+		b = protowire.AppendTag(b, 15, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	return b
+}
+
+// appendProtoString appends a string field, leaving it out when empty as
+// proto3 does.
+func appendProtoString(b []byte, num protowire.Number, s string) []byte {
+	if s == "" {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, s)
+}
+
+// appendProtoVarint appends a varint field, leaving it out when zero as
+// proto3 does.
+func appendProtoVarint(b []byte, num protowire.Number, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = protowire.AppendTag(b, num, protowire.VarintType)
+	return protowire.AppendVarint(b, v)
+}
+
+// ParseProtoReport reads a protobuf report, as written by -testivus.protofile.
+// Like ParseReport, parsed reports have an exit code of 0. Build with -tags
+// proto.
+func ParseProtoReport(r io.Reader) (*Report, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "read report")
+	}
+
+	d := newDisappointments(nil)
+	err = consumeProto(b, func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			d.Commit = string(v)
+		case num == 2 && typ == protowire.BytesType:
+			g, err := parseProtoGrievance(v)
+			if err != nil {
+				return err
+			}
+			d.Grievances[g.Name] = append(d.Grievances[g.Name], g)
+		case num == 3 && typ == protowire.BytesType:
+			var name string
+			var failed bool
+			err := consumeProto(v, func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error {
+				switch {
+				case num == 1 && typ == protowire.BytesType:
+					name = string(v)
+				case num == 2 && typ == protowire.VarintType:
+					failed = protowire.DecodeBool(n)
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+			d.Failed[name] = failed
+		case num == 4 && typ == protowire.VarintType:
+			d.Start = time.Unix(0, int64(n))
+		case num == 5 && typ == protowire.VarintType:
+			d.Seed = int64(n)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "decode report")
+	}
+	return newReport(d, 0), nil
+}
+
+func parseProtoGrievance(b []byte) (*disappointment, error) {
+	g := &disappointment{}
+	err := consumeProto(b, func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error {
+		if typ == protowire.BytesType {
+			switch num {
+			case 1:
+				g.Name = string(v)
+			case 2:
+				g.Message = string(v)
+			case 3:
+				g.Tags = append(g.Tags, string(v))
+			case 4:
+				g.Error = stderrors.New(string(v))
+			case 6:
+				g.Artifact = string(v)
+			case 8:
+				g.File = string(v)
+			case 10:
+				g.Package = string(v)
+			case 12:
+				g.UID = string(v)
+			case 15:
+				return parseProtoField(g, v)
+			}
+			return nil
+		}
+
+		switch num {
+		case 5:
+			g.Severity = Severity(n)
+		case 7:
+			g.Duration = time.Duration(n)
+		case 9:
+			g.Line = int(int32(n))
+		case 11:
+			g.Occurrences = int(int32(n))
+		case 13:
+			g.Time = time.Unix(0, int64(n))
+		case 14:
+			g.Deadline = time.Unix(0, int64(n))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	g.Path = strings.Split(g.Name, "/")
+	return g, nil
+}
+
+// parseProtoField adds a fields map entry to g.
+func parseProtoField(g *disappointment, b []byte) error {
+	var key, value string
+	err := consumeProto(b, func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error {
+		switch {
+		case num == 1 && typ == protowire.BytesType:
+			key = string(v)
+		case num == 2 && typ == protowire.BytesType:
+			value = string(v)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var v interface{}
+	if err := json.Unmarshal([]byte(value), &v); err != nil {
+		return errors.Wrapf(err, "decode field %q", key)
+	}
+	if g.Fields == nil {
+		g.Fields = make(map[string]interface{})
+	}
+	g.Fields[key] = v
+	return nil
+}
+
+// consumeProto walks the fields of a message, calling fn with the contents of
+// each length-delimited field or the value of each varint. Other wire types
+// are skipped.
+func consumeProto(b []byte, fn func(num protowire.Number, typ protowire.Type, v []byte, n uint64) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		switch typ {
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			if err := fn(num, typ, v, 0); err != nil {
+				return err
+			}
+			b = b[n:]
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			if err := fn(num, typ, nil, v); err != nil {
+				return err
+			}
+			b = b[n:]
+		default:
+			n := protowire.ConsumeFieldValue(num, typ, b)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+			b = b[n:]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+//go:build proto
+
+package testivus
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestProtoRoundTrip(t *testing.T) {
+	d := newDisappointments(nil)
+	d.record(nil, "TestB/sub", "You're slow!", []string{"speed"}).WithError(errors.New("timeout exceeded")).WithDuration(2 * time.Second)
+	d.record(nil, "TestA", "You're send too much data!", []string{"speed", "download"}).WithSeverity(Major).WithField("bytes", 1<<20)
+	d.record(nil, "TestA", "Still broken.", nil).WithDeadline(time.Now().Add(-time.Hour)).WithField("ticket", "FEST-1")
+	d.Failed["TestA"] = true
+	d.Commit = "abc123"
+	d.Start = time.Now().Add(-time.Minute)
+	d.Seed = -42
+
+	r, err := ParseProtoReport(bytes.NewReader(d.appendProto(nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want, err := json.Marshal(d.summarize())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(r.s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("summary changed in the round trip\ngot:  %s\nwant: %s", got, want)
+	}
+
+	if r.Total() != 3 || r.ByTag()["speed"] != 2 || r.ByError()["timeout exceeded"] != 1 {
+		t.Errorf("total = %d, by tag = %v, by error = %v", r.Total(), r.ByTag(), r.ByError())
+	}
+	if r.BySeverity()["Major"] != 1 || !r.Failed()["TestA"] || r.Commit() != "abc123" {
+		t.Errorf("by severity = %v, failed = %v, commit = %q", r.BySeverity(), r.Failed(), r.Commit())
+	}
+	gs := r.Grievances()
+	if len(gs) != 3 || gs[2].(*disappointment).Duration != 2*time.Second {
+		t.Fatalf("grievances = %v", gs)
+	}
+	if g := gs[0].(*disappointment); g.Fields["bytes"] != float64(1<<20) {
+		t.Errorf("fields = %v", g.Fields)
+	}
+	if g := gs[1].(*disappointment); !g.overdue() || g.Fields["ticket"] != "FEST-1" {
+		t.Errorf("deadline = %v, fields = %v", g.Deadline, g.Fields)
+	}
+	if r.d.Seed != -42 || !r.d.Start.Equal(d.Start) {
+		t.Errorf("seed = %d, start = %v", r.d.Seed, r.d.Start)
+	}
+}
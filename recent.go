@@ -0,0 +1,57 @@
+package testivus
+
+var recentWindow int
+
+// SetRecentWindow keeps the last n grievances in memory for Recent. A window of
+// zero, the default, keeps none. The summary always counts every grievance.
+func SetRecentWindow(n int) {
+	if running != nil {
+		running.Lock()
+		defer running.Unlock()
+		running.recent = nil
+		running.recentNext = 0
+	}
+	recentWindow = n
+}
+
+// Recent returns up to the last n grievances set by SetRecentWindow, oldest
+// first.
+func Recent() []Disappointment {
+	running.Lock()
+	defer running.Unlock()
+
+	r := running.recent
+	out := make([]Disappointment, 0, len(r))
+	for i := range r {
+		out = append(out, r[(running.recentNext+i)%len(r)])
+	}
+	return out
+}
+
+// remember adds g to the ring of recent grievances. The caller must hold the
+// lock.
+func (d *disappointments) remember(g *disappointment) {
+	if recentWindow <= 0 {
+		return
+	}
+
+	if len(d.recent) < recentWindow {
+		d.recent = append(d.recent, g)
+		return
+	}
+	d.recent[d.recentNext] = g
+	d.recentNext = (d.recentNext + 1) % len(d.recent)
+}
+
+// forget removes g from the ring of recent grievances. The caller must hold
+// the lock.
+func (d *disappointments) forget(g *disappointment) {
+	var kept []*disappointment
+	for i := range d.recent {
+		if r := d.recent[(d.recentNext+i)%len(d.recent)]; r != g {
+			kept = append(kept, r)
+		}
+	}
+	d.recent = kept
+	d.recentNext = 0
+}
@@ -0,0 +1,175 @@
+package testivus
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Report is a summarized set of disappointments, such as the final state of a
+// run handed to an exit policy.
+type Report struct {
+	d    *disappointments
+	s    summary
+	code int
+}
+
+func newReport(d *disappointments, code int) *Report {
+	d.Lock()
+	defer d.Unlock()
+
+	return &Report{d: d, s: d.summarize(), code: code}
+}
+
+// Total is the number of disappointments.
+func (r *Report) Total() int { return r.s.Total }
+
+// ByTag counts the disappointments by tag.
+func (r *Report) ByTag() map[string]int { return r.s.ByTag }
+
+// ByName counts the disappointments by test name.
+func (r *Report) ByName() map[string]int { return r.s.ByName }
+
+// ByError counts the disappointments by error message.
+func (r *Report) ByError() map[string]int { return r.s.ByError }
+
+// BySeverity counts the disappointments by severity name.
+func (r *Report) BySeverity() map[string]int { return r.s.BySeverity }
+
+// Grievances are all the disappointments, ordered by test name and then in the
+// order they were registered.
+func (r *Report) Grievances() []Disappointment {
+	var names []string
+	for name := range r.d.Grievances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var all []Disappointment
+	for _, name := range names {
+		for _, g := range r.d.Grievances[name] {
+			all = append(all, g)
+		}
+	}
+	return all
+}
+
+// TagsByTest counts the tags of each test, including the tags of all its
+// subtests.
+func (r *Report) TagsByTest() map[string]map[string]int { return r.s.TagsByTest }
+
+// Failed reports, for each test that registered a grievance or was tracked,
+// whether the test itself failed.
+func (r *Report) Failed() map[string]bool { return r.s.Failed }
+
+// Commit is the commit the run was attributed to, if it was known.
+func (r *Report) Commit() string { return r.d.Commit }
+
+// ExitCode is the exit code of the tests themselves.
+func (r *Report) ExitCode() int { return r.code }
+
+// exitPolicy decides the exit code of Run.
+var exitPolicy = defaultExitPolicy
+
+// defaultExitPolicy exits with the tests' own exit code, or 1 when they
+// passed but a test is over the per-test budget.
+func defaultExitPolicy(r *Report) int {
+	if r.ExitCode() == 0 && len(r.OverBudget()) > 0 {
+		return 1
+	}
+	return r.ExitCode()
+}
+
+// inCI reports whether the run looks like it is in CI, going by the CI
+// environment variable most CI services set.
+func inCI() bool {
+	ci := strings.ToLower(os.Getenv("CI"))
+	return ci != "" && ci != "false" && ci != "0"
+}
+
+// SetExitPolicy lets you decide the exit code of Run from the final report,
+// for example to fail the suite when anything Critical was registered. By
+// default Run exits with the tests' own exit code, or 1 when a test is over
+// SetPerTestBudget; a custom policy decides for itself, using
+// Report.OverBudget. Passing nil restores the default.
+func SetExitPolicy(policy func(*Report) int) {
+	if policy == nil {
+		policy = defaultExitPolicy
+	}
+	exitPolicy = policy
+}
+
+// ParseReport reads a JSON report, as written by -testivus.outputfile. Parsed
+// reports have an exit code of 0, since it isn't part of the file.
+func ParseReport(r io.Reader) (*Report, error) {
+	d := newDisappointments(nil)
+	if err := newDecoder(r).Decode(d); err != nil {
+		return nil, errors.Wrap(err, "decode report")
+	}
+	if d.Grievances == nil {
+		d.Grievances = make(map[string][]*disappointment)
+	}
+	return newSavedReport(d), nil
+}
+
+// newSavedReport reports on disappointments read back from a file. Sampling
+// and -testivus.maxfilesize leave grievances out of the file but not out of
+// its summary, so the saved counts are used when there are any. Only a file
+// without a summary is counted from its grievances.
+func newSavedReport(d *disappointments) *Report {
+	saved := d.Summary
+	r := newReport(d, 0)
+	if saved.ByName == nil {
+		return r
+	}
+
+	// what the JSON summary leaves out comes from the file itself
+	saved.Commit, saved.Seed, saved.Failed = r.s.Commit, r.s.Seed, r.s.Failed
+	saved.ScoreByName = r.s.ScoreByName
+	if saved.ByError == nil {
+		saved.ByError = make(map[string]int)
+	}
+	if saved.TagsByTest == nil {
+		saved.TagsByTest = make(map[string]map[string]int)
+	}
+	r.s = saved
+	return r
+}
+
+// newDecoder is a JSON decoder for reading back what testivus wrote. With
+// -testivus.strictdecode it rejects fields it doesn't know, so a report from
+// an incompatible version fails loudly instead of losing data.
+func newDecoder(r io.Reader) *json.Decoder {
+	dec := json.NewDecoder(r)
+	if *strictDecode {
+		dec.DisallowUnknownFields()
+	}
+	return dec
+}
+
+// unmarshal is json.Unmarshal with the same strictness as newDecoder, for
+// custom unmarshalers, which the decoder's setting doesn't reach.
+func unmarshal(b []byte, v interface{}) error {
+	dec := newDecoder(bytes.NewReader(b))
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if dec.More() {
+		return errors.New("unexpected data after JSON value")
+	}
+	return nil
+}
+
+var footer func(*Report) string
+
+// SetFooter adds a closing message to the text report, such as a link to a
+// runbook. It is given the final report so the footer can depend on it, e.g.
+// only mentioning the runbook when something Critical was registered.
+func SetFooter(f func(*Report) string) {
+	footer = f
+}
@@ -0,0 +1,1225 @@
+package testivus
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseReport(t *testing.T) {
+	d := newDisappointments(nil)
+	d.record(nil, "TestB", "You're slow!", []string{"speed"}).WithError(errors.New("timeout exceeded"))
+	d.record(nil, "TestA", "You're send too much data!", []string{"speed", "download"}).WithSeverity(Major)
+	d.Failed["TestB"] = true
+	d.Summary = d.summarize()
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := writeJSON(path, d); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	r, err := ParseReport(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Total() != 2 {
+		t.Errorf("total = %d, want 2", r.Total())
+	}
+	if r.ByTag()["speed"] != 2 || r.ByTag()["download"] != 1 {
+		t.Errorf("by tag = %v", r.ByTag())
+	}
+	if r.ByError()["timeout exceeded"] != 1 {
+		t.Errorf("by error = %v", r.ByError())
+	}
+
+	gs := r.Grievances()
+	if len(gs) != 2 || gs[0].String() != "You're send too much data! (speed, download)" {
+		t.Errorf("grievances = %v", gs)
+	}
+	if r.BySeverity()["Major"] != 1 {
+		t.Errorf("by severity = %v", r.BySeverity())
+	}
+	if !r.Failed()["TestB"] || r.Failed()["TestA"] {
+		t.Errorf("failed = %v", r.Failed())
+	}
+}
+
+func TestCompare(t *testing.T) {
+	a := newDisappointments(nil)
+	a.record(nil, "TestA", "You're slow!", []string{"speed"})
+	a.record(nil, "TestA", "You're slow!", []string{"speed"})
+	a.record(nil, "TestB", "Too much tinsel.", []string{"tinsel"})
+
+	b := newDisappointments(nil)
+	b.record(nil, "TestA", "You're slow!", []string{"speed"})
+	b.record(nil, "TestA", "You're slow!", []string{"speed"})
+	b.record(nil, "TestA", "You're slow!", []string{"speed"})
+	b.record(nil, "TestC", "I gotta lot of problems.", []string{"feats"})
+
+	d := Compare(newReport(a, 0), newReport(b, 0))
+	want := `Total: 3 -> 4 (+1, +33%)
+
+By Tag:
+ feats  0 -> 1 new
+ speed  2 -> 3 +1, +50%
+ tinsel 1 -> 0 gone
+
+By Test:
+ TestA 2 -> 3 +1, +50%
+ TestB 1 -> 0 gone
+ TestC 0 -> 1 new
+`
+	if got := d.String(); got != want {
+		t.Errorf("diff mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestStreaks(t *testing.T) {
+	run := func(tags ...string) *Report {
+		d := newDisappointments(nil)
+		for _, tag := range tags {
+			d.record(nil, "TestA", "You're slow!", []string{tag})
+		}
+		return newReport(d, 0)
+	}
+
+	streaks := Streaks([]*Report{run("speed", "tinsel"), run("tinsel"), run("feats"), run("feats")})
+	want := "speed: clean for 3 runs.\ntinsel: clean for 2 runs.\n"
+	if got := DescribeStreaks(streaks); got != want {
+		t.Errorf("streaks mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+	if streaks["feats"] != 0 {
+		t.Errorf("feats streak = %d, want 0", streaks["feats"])
+	}
+}
+
+func TestOverdue(t *testing.T) {
+	SetClock(func() time.Time { return time.Date(2024, 6, 2, 0, 0, 0, 0, time.UTC) })
+	defer SetClock(nil)
+
+	d := newDisappointments(nil)
+	d.record(nil, "TestA", "Fix the pole.", nil).WithDeadline(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	d.record(nil, "TestA", "Fix the tinsel.", nil).WithDeadline(time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC))
+	d.record(nil, "TestB", "Fix the feats.", nil)
+
+	r := newReport(d, 0)
+	if r.BySeverity()["Critical"] != 1 || r.BySeverity()["Minor"] != 2 {
+		t.Errorf("by severity = %v", r.BySeverity())
+	}
+	if rows := r.s.OverdueRows(); len(rows) != 1 || rows[0].Grievance != "Fix the pole." {
+		t.Errorf("overdue = %v", rows)
+	}
+}
+
+func TestParseGobReport(t *testing.T) {
+	d := newDisappointments(nil)
+	d.record(nil, "TestB", "You're slow!", []string{"speed"}).WithError(errors.New("timeout exceeded")).WithField("retries", 3)
+	d.record(nil, "TestA", "You're send too much data!", []string{"speed", "download"}).WithSeverity(Major)
+	d.Failed["TestB"] = true
+
+	path := filepath.Join(t.TempDir(), "report.gob")
+	if err := writeGob(path, d); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	r, err := ParseGobReport(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.Total() != 2 || r.ByError()["timeout exceeded"] != 1 || r.BySeverity()["Major"] != 1 {
+		t.Errorf("total = %d, by error = %v, by severity = %v", r.Total(), r.ByError(), r.BySeverity())
+	}
+	if !r.Failed()["TestB"] {
+		t.Errorf("failed = %v", r.Failed())
+	}
+	if gs := r.Grievances(); len(gs) != 2 || gs[1].(*disappointment).Fields["retries"] != 3.0 {
+		t.Errorf("grievances = %v", gs)
+	}
+}
+
+func TestTestWeight(t *testing.T) {
+	if err := SetTestWeight("^TestPayment", 10); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetTestWeight("(", 2); err == nil {
+		t.Error("accepted a pattern that doesn't compile")
+	}
+	if err := (Config{TestWeights: []TestWeight{{Pattern: "(", Weight: 2}}}).apply(); err == nil {
+		t.Error("config accepted a pattern that doesn't compile")
+	}
+	defer func() { testWeights = nil }()
+
+	d := newDisappointments(nil)
+	d.record(nil, "TestLogging", "Too chatty.", nil)
+	d.record(nil, "TestLogging", "Too chatty.", nil)
+	d.record(nil, "TestPayment", "Too slow.", nil)
+
+	s := d.summarize()
+	if s.ScoreByName["TestPayment"] != 10 || s.ScoreByName["TestLogging"] != 2 {
+		t.Errorf("score by name = %v", s.ScoreByName)
+	}
+	if s.nameRows[0].ID != "TestPayment" {
+		t.Errorf("by test = %v, want TestPayment first", s.nameRows)
+	}
+}
+
+func TestErrorNormalizer(t *testing.T) {
+	if err := SetErrorNormalizer(`\d+\.\d+\.\d+\.\d+(:\d+)?`, "<ip>"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetErrorNormalizer("(", ""); err == nil {
+		t.Error("accepted a pattern that doesn't compile")
+	}
+	defer func() { errorNormalizers = nil }()
+
+	d := newDisappointments(nil)
+	d.record(nil, "TestA", "Can't connect.", nil).WithError(errors.New("connection to 10.0.0.5:443 failed"))
+	d.record(nil, "TestA", "Can't connect.", nil).WithError(errors.New("connection to 10.0.0.6:443 failed"))
+
+	s := d.summarize()
+	if s.ByError["connection to <ip> failed"] != 2 {
+		t.Errorf("by error = %v", s.ByError)
+	}
+	if ex := s.ErrorExample("connection to <ip> failed"); ex != "connection to 10.0.0.5:443 failed" {
+		t.Errorf("example = %q", ex)
+	}
+}
+
+func TestConfig(t *testing.T) {
+	for _, c := range []Config{
+		{RegexDimensions: []RegexDimension{{Name: "endpoint", Pattern: "no group"}}},
+		{ErrorNormalizers: []ErrorNormalizer{{Pattern: "("}}},
+		{DurationUnit: 3 * time.Millisecond},
+	} {
+		if err := c.apply(); err == nil {
+			t.Errorf("applied %+v", c)
+		}
+	}
+	errorNormalizers, dimensions = nil, nil
+
+	outage := errors.New("outage")
+	c := Config{
+		Format:             "json",
+		Escalations:        []Escalation{{Tag: "speed", Count: 1, To: Major}},
+		ErrorSeverities:    []ErrorSeverity{{Target: outage, Severity: Critical}},
+		SeverityClassifier: func(Disappointment) Severity { return 0 },
+		RecentWindow:       2,
+		DedupKey:           func(d Disappointment) string { return d.String() },
+		RegexDimensions:    []RegexDimension{{Name: "endpoint", Pattern: `call to (\S+)`}},
+		ErrorNormalizers:   []ErrorNormalizer{{Pattern: `\d+`, Replacement: "<n>"}},
+		DurationUnit:       time.Millisecond,
+		JSONFieldNames:     map[string]string{"message": "msg"},
+	}
+	if err := c.apply(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		*format = "text"
+		delete(escalations, "speed")
+		errorSeverities, severityClassifier, dedupKeyFunc = nil, nil, nil
+		errorNormalizers, dimensions, jsonFieldNames = nil, nil, nil
+		SetRecentWindow(0)
+		SetDurationFormat(0, 0)
+	}()
+
+	if *format != "json" || escalations["speed"].to != Major || len(errorSeverities) != 1 || severityClassifier == nil ||
+		recentWindow != 2 || dedupKeyFunc == nil || len(dimensions) != 1 || len(errorNormalizers) != 1 ||
+		durationUnit != time.Millisecond || jsonFieldNames["message"] != "msg" {
+		t.Errorf("config not applied")
+	}
+}
+
+func TestFit(t *testing.T) {
+	d := newDisappointments(nil)
+	for i := 0; i < 20; i++ {
+		d.record(nil, "TestA", "Not again.", []string{"minor"})
+	}
+	d.record(nil, "TestB", "The whole thing is on fire.", nil).WithSeverity(Critical)
+	d.Summary = d.summarize()
+
+	f, err := d.fit(1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := json.Marshal(f)
+	if len(b) > 1000 {
+		t.Errorf("size = %d", len(b))
+	}
+	if f.Summary.Omitted == 0 || f.Summary.Total != 21 {
+		t.Errorf("omitted = %d, total = %d", f.Summary.Omitted, f.Summary.Total)
+	}
+	if len(f.Grievances["TestB"]) != 1 {
+		t.Errorf("critical grievance dropped")
+	}
+
+	again, _ := d.fit(1000)
+	if b2, _ := json.Marshal(again); !bytes.Equal(b, b2) {
+		t.Error("fit kept different grievances the second time")
+	}
+
+	r, err := ParseReport(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Total() != 21 {
+		t.Errorf("parsed total = %d, want 21", r.Total())
+	}
+
+	if _, err := d.fit(10); err == nil {
+		t.Error("fit a report into 10 bytes")
+	}
+}
+
+func TestByTimeBucket(t *testing.T) {
+	run := func(times ...time.Time) *Report {
+		d := newDisappointments(nil)
+		for _, at := range times {
+			d.record(nil, "TestA", "You're slow!", nil).Time = at
+		}
+		d.record(nil, "TestA", "No idea when.", nil).Time = time.Time{}
+		return newReport(d, 0)
+	}
+
+	// 2024-01-02 was a Tuesday
+	night := time.Date(2024, time.January, 2, 2, 10, 0, 0, time.UTC)
+	buckets := ByTimeBucket([]*Report{
+		run(night, night.Add(40*time.Minute)),
+		run(night.Add(7*24*time.Hour), night.Add(13*time.Hour)),
+	}, time.Hour)
+
+	want := "Tue 02:00: 3 grievances.\nTue 15:00: 1 grievance.\n"
+	if got := DescribeTimeBuckets(buckets); got != want {
+		t.Errorf("buckets mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestStrictDecode(t *testing.T) {
+	*strictDecode = true
+	defer func() { *strictDecode = false }()
+
+	d := newDisappointments(nil)
+	d.record(nil, "TestA", "You're slow!", []string{"speed"}).WithError(fmt.Errorf("waiting: %w", errors.New("timeout exceeded")))
+	d.record(nil, "FuzzB", "Odd input.", nil)
+	d.Summary = d.summarize()
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ParseReport(bytes.NewReader(b)); err != nil {
+		t.Errorf("own report rejected: %v", err)
+	}
+
+	for _, report := range []string{
+		`{"grievances": {}, "summary": {}, "version": 2}`,
+		`{"grievances": {}, "summary": {"byMood": {}}}`,
+		`{"grievances": {"TestA": [{"message": "Hmm.", "mood": "sour"}]}, "summary": {}}`,
+	} {
+		if _, err := ParseReport(strings.NewReader(report)); err == nil {
+			t.Errorf("accepted %s", report)
+		}
+	}
+}
+
+func TestDurationFormat(t *testing.T) {
+	if err := SetDurationFormat(10*time.Millisecond, 2); err == nil {
+		t.Error("accepted 10ms as a unit")
+	}
+	if err := SetDurationFormat(time.Millisecond, 2); err != nil {
+		t.Fatal(err)
+	}
+	defer SetDurationFormat(0, 0)
+
+	d := newDisappointments(nil)
+	d.record(nil, "TestA", "You're slow!", []string{"speed"}).WithDuration(12345678 * time.Nanosecond)
+	d.Summary = d.summarize()
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(b, []byte(`"duration":"12.35ms"`)) || !bytes.Contains(b, []byte(`"p50":"12.35ms"`)) {
+		t.Errorf("durations not formatted in %s", b)
+	}
+
+	r, err := ParseReport(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := r.d.Grievances["TestA"][0].Duration; got != 12350*time.Microsecond {
+		t.Errorf("duration read back as %v", got)
+	}
+
+	SetDurationFormat(0, 0)
+	if got := formatDuration(1500 * time.Millisecond); got != "1.5s" {
+		t.Errorf("default format = %q", got)
+	}
+}
+
+// abortTB records the Fatalf that -testivus.abortoncritical ends a test with,
+// without ending the real one.
+type abortTB struct {
+	testing.TB
+	fatal string
+}
+
+func (a *abortTB) Fatalf(format string, args ...interface{}) {
+	a.fatal = fmt.Sprintf(format, args...)
+}
+
+func TestAbortOnCritical(t *testing.T) {
+	*abortCritical = true
+	failFast := flag.Lookup("test.failfast").Value.String()
+	defer func() {
+		*abortCritical = false
+		flag.Set("test.failfast", failFast)
+	}()
+
+	tb := &abortTB{TB: t}
+	d := newDisappointments(nil)
+	g := d.record(tb, "TestA", "Not again.", nil)
+	abortIfCritical(g)
+	if tb.fatal != "" {
+		t.Fatalf("aborted on a Minor grievance: %s", tb.fatal)
+	}
+
+	g.WithSeverity(Critical)
+	if tb.fatal != "testivus: aborting on Critical grievance: Not again." {
+		t.Errorf("fatal = %q", tb.fatal)
+	}
+	if flag.Lookup("test.failfast").Value.String() != "true" {
+		t.Error("-test.failfast not set")
+	}
+
+	outage := errors.New("outage")
+	RegisterErrorSeverity(outage, Critical)
+	defer func() { errorSeverities = nil }()
+
+	tb.fatal = ""
+	d.record(tb, "TestA", "Down again.", nil).WithError(outage)
+	if tb.fatal == "" {
+		t.Error("did not abort on a Critical error")
+	}
+
+	tb.fatal = ""
+	d.record(tb, "TestA", "Still broken.", nil).WithDeadline(time.Now().Add(-time.Hour))
+	if tb.fatal == "" {
+		t.Error("did not abort on an overdue grievance")
+	}
+
+	tb.fatal = ""
+	NewBatch(tb).Add("In bulk.").WithSeverity(Critical)
+	if tb.fatal == "" {
+		t.Error("did not abort on a batch grievance")
+	}
+
+	hooks = append(hooks, func(g Disappointment) { g.WithSeverity(Critical) })
+	defer func() { hooks = hooks[:len(hooks)-1] }()
+	tb.fatal = ""
+	d.Lock()
+	g = d.record(tb, "TestA", "Raised by a hook.", nil)
+	if tb.fatal != "" {
+		t.Errorf("aborted with the lock held: %s", tb.fatal)
+	}
+	d.Unlock()
+	abortIfCritical(g)
+	if tb.fatal == "" {
+		t.Error("did not abort once the lock was released")
+	}
+}
+
+// stopTB counts the FailNow calls SetFailFastPerTest stops a test with,
+// without stopping the real one.
+type stopTB struct {
+	testing.TB
+	stops int
+}
+
+func (s *stopTB) FailNow() {
+	s.stops++
+}
+
+func TestFailFastEverywhere(t *testing.T) {
+	SetFailFastPerTest(true)
+	defer SetFailFastPerTest(false)
+
+	tb := &stopTB{TB: t}
+	GrievanceOnce(t.Name(), tb, "Once.")
+	GrievanceOnce(t.Name(), tb, "Once again.")
+	GrievanceSampled(tb, 1, "Sampled.")
+	b := NewBatch(tb)
+	b.Add("Batched.")
+	b.Add("Batched again.")
+	b.Flush()
+	end := Section(tb, "festivus")
+	Grievance(tb, "In a section.")
+	end()
+	if tb.stops != 6 {
+		t.Errorf("stopped %d times, want 6", tb.stops)
+	}
+
+	reached := false
+	testing.Benchmark(func(b *testing.B) {
+		TimedB(b, -1, "Too slow.")()
+		reached = true
+	})
+	if reached {
+		t.Error("TimedB didn't stop the benchmark")
+	}
+}
+
+func TestTrends(t *testing.T) {
+	run := func(tags ...string) *Report {
+		d := newDisappointments(nil)
+		for _, tag := range tags {
+			d.record(nil, "TestA", "You're slow!", []string{tag})
+		}
+		return newReport(d, 0)
+	}
+
+	trends := Trends([]*Report{
+		run("tinsel"),
+		run("speed"),
+		run("speed", "speed", "speed", "tinsel"),
+		run("speed", "speed", "speed", "speed", "speed", "speed", "speed"),
+	}, 3)
+	if _, ok := trends["tinsel"]; !ok || len(trends["speed"]) != 3 {
+		t.Fatalf("trends = %v", trends)
+	}
+
+	t.Setenv("LC_ALL", "en_US.UTF-8")
+	want := "speed  ▂▄█ 1 -> 7\ntinsel ▁█▁ 0 -> 0\n"
+	if got := DescribeTrends(trends); got != want {
+		t.Errorf("sparklines mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+
+	t.Setenv("LC_ALL", "C")
+	want = "speed  1 3 7\ntinsel 0 1 0\n"
+	if got := DescribeTrends(trends); got != want {
+		t.Errorf("numbers mismatch\ngot:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestUpload(t *testing.T) {
+	saved := make(map[string]func(string, []byte) error)
+	for scheme, up := range uploaders {
+		saved[scheme] = up
+	}
+	defer func() { uploaders = saved }()
+	delete(uploaders, "gs")
+
+	var got string
+	uploaders["s3"] = func(url string, data []byte) error {
+		got = url + " " + string(data)
+		return nil
+	}
+
+	d := newDisappointments(nil)
+	d.record(nil, "TestA", "You're slow!", nil)
+	if err := writeText("s3://reports/run.txt", d); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(got, "s3://reports/run.txt ") {
+		t.Errorf("uploaded %q", got)
+	}
+
+	if err := writeText("gs://reports/run.txt", d); err == nil || !strings.Contains(err.Error(), "-tags gcs") {
+		t.Errorf("err = %v, want a hint to build with gcs", err)
+	}
+	if _, _, err := splitBucketURL("s3://reports"); err == nil {
+		t.Error("accepted a URL without a key")
+	}
+}
+
+// jsonStore keeps grievances encoded, like a store backed by a database.
+type jsonStore struct {
+	rows [][]byte
+}
+
+func (s *jsonStore) Add(d Disappointment) {
+	b, err := MarshalGrievance(d)
+	if err != nil {
+		panic(err)
+	}
+	s.rows = append(s.rows, b)
+}
+
+func (s *jsonStore) Range(fn func(d Disappointment) bool) {
+	for _, b := range s.rows {
+		g, err := UnmarshalGrievance(b)
+		if err != nil {
+			panic(err)
+		}
+		if !fn(g) {
+			return
+		}
+	}
+}
+
+// foreign is a Disappointment testivus didn't make.
+type foreign struct{ Disappointment }
+
+type foreignStore struct{ n int }
+
+func (s *foreignStore) Add(d Disappointment) { s.n++ }
+
+func (s *foreignStore) Range(fn func(d Disappointment) bool) {
+	for i := 0; i < s.n; i++ {
+		fn(foreign{})
+	}
+}
+
+func TestCustomStore(t *testing.T) {
+	SetPerTestLimit(2)
+	defer SetPerTestLimit(0)
+
+	d := newDisappointments(nil)
+	d.store = &jsonStore{}
+	d.record(nil, "TestA", "You're slow!", []string{"speed"}).WithSeverity(Major)
+	d.record(nil, "TestA", "Too much tinsel.", nil)
+	d.record(nil, "TestA", "Dropped.", nil)
+
+	if n := d.total(); n != 2 {
+		t.Errorf("total = %d, want 2", n)
+	}
+	if len(d.Grievances) != 0 {
+		t.Errorf("grievances kept in memory: %v", d.Grievances)
+	}
+	if c := d.withStored(); len(c.Grievances["TestA"]) != 2 {
+		t.Errorf("checkpoint grievances = %v", c.Grievances)
+	}
+
+	d.collect()
+	if s := d.summarize(); s.Total != 2 || s.Dropped["TestA"] != 1 {
+		t.Errorf("total = %d, dropped = %v", s.Total, s.Dropped)
+	}
+	d.writeFailed(errors.New("disk full"))
+	if s := d.summarize(); s.Total != 3 || s.ByName["testivus"] != 1 {
+		t.Errorf("write failure not reported: total = %d, by test = %v", s.Total, s.ByName)
+	}
+
+	f := newDisappointments(nil)
+	f.store = &foreignStore{}
+	f.record(nil, "TestA", "You're slow!", nil)
+	f.collect()
+	if g := f.Grievances["testivus"]; len(g) != 1 || !strings.Contains(g[0].Error.Error(), "1 grievances left out") {
+		t.Errorf("unreadable grievances not reported: %v", f.Grievances)
+	}
+}
+
+func TestSampleSeed(t *testing.T) {
+	SetSeveritySampling(map[Severity]float64{Minor: 0.5})
+	defer SetSeveritySampling(nil)
+	defer SetSeed(time.Now().UnixNano())
+
+	d := newDisappointments(nil)
+	for i := 0; i < 20; i++ {
+		d.record(nil, fmt.Sprintf("Test%d", i), "Not again.", nil)
+	}
+	d.Summary = d.summarize()
+
+	kept := func() string {
+		SetSeed(42)
+		var names []string
+		for name := range d.sample().Grievances {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		return strings.Join(names, ",")
+	}
+	if a, b := kept(), kept(); a != b {
+		t.Errorf("same seed kept %s, then %s", a, b)
+	}
+}
+
+func TestSeverityFileDowngrade(t *testing.T) {
+	downgradePattern = regexp.MustCompile("flaky")
+	defer func() { downgradePattern = nil }()
+	path := filepath.Join(t.TempDir(), "critical.json")
+	severityOut[Critical] = []string{path}
+	defer delete(severityOut, Critical)
+
+	d := newDisappointments(nil)
+	d.record(nil, "TestA", "On fire.", nil).WithSeverity(Critical)
+	d.record(nil, "TestA", "On fire again.", []string{"flaky"}).WithSeverity(Critical)
+	if err := report(d, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r, err := ParseReport(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Total() != 1 {
+		t.Errorf("critical file has %v", r.Grievances())
+	}
+	if golden := d.golden(); !strings.Contains(golden, "[Info] On fire again.") {
+		t.Errorf("golden report doesn't show the downgrade:\n%s", golden)
+	}
+}
+
+func TestWithSeverityInHook(t *testing.T) {
+	SetMinSeverity(Major)
+	defer SetMinSeverity(0)
+	hooks = append(hooks, func(g Disappointment) {
+		if strings.Contains(g.String(), "tinsel") {
+			g.WithSeverity(Info)
+		}
+	})
+	defer func() { hooks = hooks[:len(hooks)-1] }()
+
+	d := newDisappointments(nil)
+	d.Lock()
+	d.record(nil, "TestA", "I find tinsel distracting.", nil).WithSeverity(Major)
+	raised := d.record(nil, "TestA", "You're slow!", nil)
+	d.Unlock()
+	raised.WithSeverity(Critical)
+
+	s := d.summarize()
+	if s.Total != 1 || s.Suppressed != 1 {
+		t.Errorf("total = %d, suppressed = %d, want 1 and 1", s.Total, s.Suppressed)
+	}
+	if v := d.Grievances["TestA"]; len(v) != 1 || v[0] != raised {
+		t.Errorf("stored %v, want only the raised grievance", v)
+	}
+}
+
+func TestCheckEqualFields(t *testing.T) {
+	CheckEqual(t, func() {}, math.NaN(), "Unencodable")
+	if _, err := json.Marshal(Sequence(t)); err != nil {
+		t.Errorf("grievance can't be encoded: %v", err)
+	}
+}
+
+func TestMarkdownTagLinks(t *testing.T) {
+	if err := SetTagLinks(map[string]string{"speed": "https://grafana/d/tests?var-tag={{.Tag"}); err == nil {
+		t.Error("unparseable tag link accepted")
+	}
+	if err := (Config{TagLinks: map[string]string{"speed": "{{"}}).apply(); err == nil {
+		t.Error("config with an unparseable tag link applied")
+	}
+	if err := SetTagLinks(map[string]string{"speed": "https://grafana/d/tests?var-tag={{.Tag}}"}); err != nil {
+		t.Fatal(err)
+	}
+	defer SetTagLinks(nil)
+
+	d := newDisappointments(nil)
+	d.record(nil, "TestA", "You're slow!", []string{"speed", "tinsel"})
+
+	md := d.summarize().markdown()
+	if !strings.Contains(md, "| [speed](https://grafana/d/tests?var-tag=speed) | 1 |") {
+		t.Errorf("tag link missing:\n%s", md)
+	}
+	if !strings.Contains(md, "| tinsel | 1 |") {
+		t.Errorf("unlinked tag missing:\n%s", md)
+	}
+}
+
+func TestFoldCaseLookups(t *testing.T) {
+	*foldCase = true
+	defer func() { *foldCase = false }()
+	SetEscalation("speed", 1, Critical)
+	defer delete(escalations, "speed")
+	SetTagLinks(map[string]string{"SPEED": "https://grafana/d/tests?var-tag={{.Tag}}"})
+	defer SetTagLinks(nil)
+
+	d := newDisappointments(nil)
+	d.record(nil, "TestA", "You're slow!", []string{"Speed"})
+	d.record(nil, "TestB", "Still slow.", []string{"speed "})
+	d.record(nil, "TestC", "Slower still.", []string{"speed"})
+	d.record(nil, "TestD", "Slowest.", []string{"speed"})
+
+	s := d.summarize()
+	if s.Escalated["speed"] != Critical {
+		t.Errorf("escalated = %v", s.Escalated)
+	}
+	if s.BySeverity["Critical"] != 4 {
+		t.Errorf("by severity = %v", s.BySeverity)
+	}
+	if md := s.markdown(); !strings.Contains(md, "[speed](https://grafana/d/tests?var-tag=speed)") {
+		t.Errorf("tag link missing:\n%s", md)
+	}
+}
+
+func TestCheckpoint(t *testing.T) {
+	SetPerTestLimit(1)
+	defer SetPerTestLimit(0)
+
+	d := newDisappointments(nil)
+	d.record(nil, "TestA", "You're slow!", nil)
+	d.record(nil, "TestA", "Dropped.", nil)
+	d.once["slow"] = d.Grievances["TestA"][0]
+	d.Failed["TestA"] = true
+	d.tests["TestB"] = true
+	d.suppressed, d.skipped = 2, 3
+
+	path := filepath.Join(t.TempDir(), "checkpoint.json")
+	if err := d.checkpoint(path); err != nil {
+		t.Fatal(err)
+	}
+
+	r := newDisappointments(nil)
+	if err := r.load(path); err != nil {
+		t.Fatal(err)
+	}
+	s := r.summarize()
+	if s.Total != 1 || s.Tests != 2 || !s.Failed["TestA"] || s.Dropped["TestA"] != 1 || s.Suppressed != 2 || s.Skipped != 3 {
+		t.Errorf("resumed summary = %+v", s)
+	}
+	if g := r.once["slow"]; g == nil || g != r.Grievances["TestA"][0] {
+		t.Errorf("once key not restored: %v", r.once)
+	}
+
+	if err := d.checkpoint("s3://reports/checkpoint.json"); err == nil {
+		t.Error("checkpointed to an upload URL")
+	}
+	if err := r.load("gs://reports/checkpoint.json"); err == nil {
+		t.Error("loaded from an upload URL")
+	}
+}
+
+func TestParseSampledReport(t *testing.T) {
+	SetSeveritySampling(map[Severity]float64{Minor: 0})
+	defer SetSeveritySampling(nil)
+
+	d := newDisappointments(nil)
+	for i := 0; i < 5; i++ {
+		d.record(nil, "TestA", "Not again.", []string{"speed"})
+	}
+	d.record(nil, "TestB", "On fire.", []string{"fire"}).WithSeverity(Critical)
+	d.Summary = d.summarize()
+
+	path := filepath.Join(t.TempDir(), "report.json")
+	if err := writeSampledJSON(path, d); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	r, err := ParseReport(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(r.Grievances()) != 1 {
+		t.Errorf("sampled file has %d grievances, want 1", len(r.Grievances()))
+	}
+	if r.Total() != 6 || r.ByTag()["speed"] != 5 || r.ByName()["TestA"] != 5 || r.BySeverity()["Minor"] != 5 {
+		t.Errorf("total = %d, by tag = %v, by name = %v, by severity = %v", r.Total(), r.ByTag(), r.ByName(), r.BySeverity())
+	}
+}
+
+func TestBudgetExitPolicy(t *testing.T) {
+	SetPerTestBudget(1)
+	defer SetPerTestBudget(0)
+
+	d := newDisappointments(nil)
+	d.record(nil, "TestA", "Not again.", nil)
+	d.record(nil, "TestA", "And again.", nil)
+	d.record(nil, "TestB", "Just once.", nil)
+	r := newReport(d, 0)
+
+	if over := r.OverBudget(); len(over) != 1 || over["TestA"] != 2 {
+		t.Errorf("over budget = %v", over)
+	}
+	if code := exitCode(r); code != 1 {
+		t.Errorf("default policy exit code = %d, want 1", code)
+	}
+
+	t.Setenv("CI", "")
+	*ciOnly = true
+	if code := exitCode(r); code != 0 {
+		t.Errorf("exit code outside CI = %d, want 0", code)
+	}
+	*ciOnly = false
+
+	SetExitPolicy(func(r *Report) int { return r.ExitCode() })
+	defer SetExitPolicy(nil)
+	if code := exitCode(r); code != 0 {
+		t.Errorf("custom policy exit code = %d, want 0", code)
+	}
+}
+
+func TestEscalation(t *testing.T) {
+	for _, tc := range []struct {
+		name       string
+		tags       []string
+		critical   bool
+		count      int
+		escalated  map[string]Severity
+		bySeverity map[string]int
+	}{
+		{"under the limit", []string{"speed", "speed"}, false, 2, map[string]Severity{}, map[string]int{"Minor": 2}},
+		{"over the limit", []string{"speed", "speed", "speed"}, false, 2, map[string]Severity{"speed": Major}, map[string]int{"Major": 3}},
+		{"only the tag", []string{"speed", "speed", "tinsel"}, false, 1, map[string]Severity{"speed": Major}, map[string]int{"Major": 2, "Minor": 1}},
+		{"never lowers", []string{"speed", "speed"}, true, 1, map[string]Severity{"speed": Major}, map[string]int{"Critical": 1, "Major": 1}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			SetEscalation("speed", tc.count, Major)
+			defer delete(escalations, "speed")
+
+			d := newDisappointments(nil)
+			for i, tag := range tc.tags {
+				g := d.record(nil, "TestA", "You're slow!", []string{tag})
+				if tc.critical && i == 0 {
+					g.WithSeverity(Critical)
+				}
+			}
+
+			s := d.summarize()
+			if fmt.Sprint(s.Escalated) != fmt.Sprint(tc.escalated) {
+				t.Errorf("escalated = %v, want %v", s.Escalated, tc.escalated)
+			}
+			if fmt.Sprint(s.BySeverity) != fmt.Sprint(tc.bySeverity) {
+				t.Errorf("by severity = %v, want %v", s.BySeverity, tc.bySeverity)
+			}
+		})
+	}
+}
+
+func TestRecent(t *testing.T) {
+	defer SetRecentWindow(0)
+
+	for _, tc := range []struct {
+		name    string
+		window  int
+		n       int
+		retract bool
+		want    []string
+	}{
+		{"off", 0, 3, false, []string{}},
+		{"not full", 3, 2, false, []string{"1", "2"}},
+		{"wrapped", 3, 5, false, []string{"3", "4", "5"}},
+		{"retracted", 3, 4, true, []string{"2", "3"}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			SetRecentWindow(tc.window)
+			var last Disappointment
+			for i := 1; i <= tc.n; i++ {
+				last = Grievance(t, fmt.Sprint(i))
+			}
+			if tc.retract {
+				Retract(last)
+			}
+
+			got := []string{}
+			for _, g := range Recent() {
+				got = append(got, g.String())
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+				t.Errorf("recent = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSectionNesting(t *testing.T) {
+	before := len(Sequence(t))
+	endOuter := Section(t, "outer")
+	Grievance(t, "Before the pole.")
+	endInner := Section(t, "inner")
+	Grievance(t, "At the pole.")
+	endInner()
+	endOuter()
+	Grievance(t, "After the pole.")
+
+	seq := Sequence(t)[before:]
+	for i, want := range []string{
+		"Before the pole. (section:outer)",
+		"At the pole. (section:outer, section:inner)",
+		"section inner had 1 grievances (section:inner, section:outer)",
+		"section outer had 2 grievances (section:outer)",
+		"After the pole.",
+	} {
+		if i >= len(seq) {
+			t.Fatalf("got %d grievances, want 5: %v", len(seq), seq)
+		}
+		if seq[i].String() != want {
+			t.Errorf("grievance %d = %q, want %q", i, seq[i], want)
+		}
+	}
+}
+
+func TestFoldCase(t *testing.T) {
+	defer func() { *foldCase = false }()
+
+	for _, tc := range []struct {
+		name string
+		fold bool
+		tags []string
+		want map[string]int
+	}{
+		{"off", false, []string{"Speed", "speed"}, map[string]int{"Speed": 1, "speed": 1}},
+		{"most common spelling", true, []string{"speed", "SPEED", "speed"}, map[string]int{"speed": 3}},
+		{"ties alphabetically", true, []string{"Speed", "speed ", "speed"}, map[string]int{"Speed": 3}},
+		{"other tags alone", true, []string{"speed", "tinsel"}, map[string]int{"speed": 1, "tinsel": 1}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			*foldCase = tc.fold
+			d := newDisappointments(nil)
+			for _, tag := range tc.tags {
+				d.record(nil, "TestA", "You're slow!", []string{tag})
+			}
+
+			if s := d.summarize(); fmt.Sprint(s.ByTag) != fmt.Sprint(tc.want) {
+				t.Errorf("by tag = %v, want %v", s.ByTag, tc.want)
+			}
+		})
+	}
+}
+
+func TestDedupKey(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		key  func(Disappointment) string
+		want int
+	}{
+		{"same test", nil, 2},
+		{"any test", func(d Disappointment) string { return d.String() }, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			SetDedupKey(tc.key)
+			defer SetDedupKey(nil)
+
+			d := newDisappointments(nil)
+			d.record(nil, "TestA", "You're slow!", []string{"speed"})
+			d.record(nil, "TestB", "You're slow!", []string{"speed"})
+			d.record(nil, "TestA", "You're slow!", []string{"speed"})
+			d.record(nil, "TestA", "Too much tinsel.", nil)
+
+			rows := d.summarize().DuplicateRows()
+			if len(rows) != 1 || rows[0].ID != "You're slow! (speed)" || rows[0].Count != tc.want {
+				t.Errorf("duplicates = %v, want %d of You're slow!", rows, tc.want)
+			}
+		})
+	}
+}
+
+func TestFormats(t *testing.T) {
+	d := newDisappointments(nil)
+	d.record(nil, "TestA", "You're slow!", []string{"speed"})
+	d.record(nil, "TestA", "You're send too much data!", []string{"speed", "download"})
+	d.record(nil, "TestB", "My son tells me your company stinks!", nil).WithSeverity(Critical)
+
+	for _, tc := range []struct {
+		format string
+		d      *disappointments
+		want   string
+	}{
+		{"compact", d, "speed=2\ndownload=1\n"},
+		{"compact", newDisappointments(nil), "total=0\n"},
+		{"severity-tag", d, `I got a lot of problems with you people! (3 disappointments)
+
+Critical (1):
+ (untagged) 1 |
+
+Minor (2):
+ speed    2 ||
+ download 1 |
+`},
+		{"boxed", d, `I got a lot of problems with you people! (3 disappointments across 2 tests)
+
+By Tag:
++----------+-------+
+| tag      | count |
++----------+-------+
+| speed    |     2 |
+| download |     1 |
++----------+-------+
+
+Tags Together:
++------------------+-------+
+| tags             | count |
++------------------+-------+
+| download + speed |     1 |
++------------------+-------+
+
+By Test:
++-------+-------+
+| test  | count |
++-------+-------+
+| TestA |     2 |
+| TestB |     1 |
++-------+-------+
+`},
+	} {
+		if got := formats[tc.format](tc.d); got != tc.want {
+			t.Errorf("%s mismatch\ngot:\n%s\nwant:\n%s", tc.format, got, tc.want)
+		}
+	}
+}
+
+func TestCIOnly(t *testing.T) {
+	SetPerTestBudget(1)
+	defer SetPerTestBudget(0)
+	defer func() { *ciOnly = false }()
+
+	d := newDisappointments(nil)
+	d.record(nil, "TestA", "Not again.", nil)
+	d.record(nil, "TestA", "And again.", nil)
+	r := newReport(d, 0)
+
+	for _, tc := range []struct {
+		ci     string
+		ciOnly bool
+		want   int
+	}{
+		{"", false, 1},
+		{"", true, 0},
+		{"false", true, 0},
+		{"true", true, 1},
+		{"1", true, 1},
+	} {
+		t.Setenv("CI", tc.ci)
+		*ciOnly = tc.ciOnly
+		if code := exitCode(r); code != tc.want {
+			t.Errorf("CI=%q, cionly=%v: exit code = %d, want %d", tc.ci, tc.ciOnly, code, tc.want)
+		}
+	}
+}
+
+// logTB records what is logged instead of logging it.
+type logTB struct {
+	testing.TB
+	logs []string
+}
+
+func (l *logTB) Log(args ...interface{}) {
+	l.logs = append(l.logs, fmt.Sprint(args...))
+}
+
+func TestLiveFormat(t *testing.T) {
+	*testLog = true
+	defer func() {
+		*testLog = false
+		*liveFormat = "default"
+	}()
+
+	for _, tc := range []struct {
+		format string
+		want   string
+	}{
+		{"default", "GRIEVANCE: You're slow! (speed)"},
+		{"prefix", "[TestA][Major] You're slow! (speed)"},
+	} {
+		*liveFormat = tc.format
+		tb := &logTB{TB: t}
+		g := newGrievance("TestA", "You're slow!", []string{"speed"})
+		g.Severity = Major
+		announce(tb, g)
+		if len(tb.logs) != 1 || tb.logs[0] != tc.want {
+			t.Errorf("%s: logged %q, want %q", tc.format, tb.logs, tc.want)
+		}
+	}
+}
+
+func TestTimeline(t *testing.T) {
+	start := time.Date(2024, 12, 23, 18, 0, 0, 0, time.UTC)
+	defer func() { *timelineFormat = "" }()
+	defer SetDurationFormat(0, 0)
+
+	for _, tc := range []struct {
+		format string
+		unit   time.Duration
+		want   []string
+	}{
+		{"", 0, nil},
+		{"relative", 0, []string{"+1.000s", "+2.500s"}},
+		{"relative", time.Millisecond, []string{"+1000ms", "+2500ms"}},
+		{"absolute", 0, []string{"2024-12-23T18:00:01Z", "2024-12-23T18:00:02.5Z"}},
+	} {
+		*timelineFormat = tc.format
+		SetDurationFormat(tc.unit, 0)
+
+		d := newDisappointments(nil)
+		d.Start = start
+		d.record(nil, "TestB", "Too late.", nil).Time = start.Add(2500 * time.Millisecond)
+		d.record(nil, "TestA", "Late.", nil).Time = start.Add(time.Second)
+
+		var got []string
+		for _, r := range d.timeline() {
+			got = append(got, r.At)
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tc.want) {
+			t.Errorf("%s in %v: timeline = %q, want %q", tc.format, tc.unit, got, tc.want)
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	if err := openEvents(path); err != nil {
+		t.Fatal(err)
+	}
+	emit("start", map[string]interface{}{"commit": "abc123"})
+	d := newDisappointments(nil)
+	d.record(nil, "TestA", "You're slow!", nil)
+	emit("end", map[string]interface{}{"exitCode": 0})
+	closeEvents()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(string(b)), "\n") {
+		var e struct {
+			Event     string `json:"event"`
+			Time      string `json:"time"`
+			Commit    string `json:"commit"`
+			Grievance *struct {
+				Message string `json:"message"`
+			} `json:"grievance"`
+		}
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatalf("%q: %v", line, err)
+		}
+		if e.Time == "" {
+			t.Errorf("%s event has no time", e.Event)
+		}
+		switch {
+		case e.Commit != "":
+			got = append(got, e.Event+" "+e.Commit)
+		case e.Grievance != nil:
+			got = append(got, e.Event+" "+e.Grievance.Message)
+		default:
+			got = append(got, e.Event)
+		}
+	}
+
+	want := []string{"start abc123", "grievance You're slow!", "end"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,40 @@
+//go:build s3
+
+package testivus
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/pkg/errors"
+)
+
+func init() {
+	uploaders["s3"] = uploadS3
+}
+
+// uploadS3 puts a report at an s3://bucket/key URL, with credentials and
+// region from the usual AWS environment variables and config files. Build
+// with -tags s3.
+func uploadS3(url string, data []byte) error {
+	bucket, key, err := splitBucketURL(url)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	cfg, err := config.LoadDefaultConfig(ctx)
+	if err != nil {
+		return errors.Wrap(err, "load AWS config")
+	}
+
+	_, err = s3.NewFromConfig(cfg).PutObject(ctx, &s3.PutObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+		Body:   bytes.NewReader(data),
+	})
+	return errors.Wrap(err, "upload to S3")
+}
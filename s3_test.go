@@ -0,0 +1,41 @@
+//go:build s3
+
+package testivus
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestUploadS3(t *testing.T) {
+	var method, path, body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, string(b)
+	}))
+	defer srv.Close()
+
+	// a local endpoint with static credentials, so nothing leaves the machine
+	none := filepath.Join(t.TempDir(), "none")
+	t.Setenv("AWS_CONFIG_FILE", none)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", none)
+	t.Setenv("AWS_ACCESS_KEY_ID", "festivus")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "feats-of-strength")
+	t.Setenv("AWS_REGION", "us-east-1")
+	t.Setenv("AWS_ENDPOINT_URL_S3", srv.URL)
+
+	if err := uploadS3("s3://reports/runs/report.json", []byte(`{"total":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPut || path != "/reports/runs/report.json" || !strings.Contains(body, `{"total":1}`) {
+		t.Errorf("got %s %s with %q", method, path, body)
+	}
+
+	if err := uploadS3("s3://reports", nil); err == nil {
+		t.Error("uploaded to a URL without a key")
+	}
+}
@@ -0,0 +1,170 @@
+package testivus
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+	"time"
+)
+
+var (
+	// seed is what rng was seeded with, recorded in the report so a run can
+	// be reproduced with -testivus.seed.
+	seed = time.Now().UnixNano()
+	// rng drives all of testivus' sampling.
+	rng = rand.New(rand.NewSource(seed))
+)
+
+var severitySampling map[Severity]float64
+
+// SetSeveritySampling keeps only a fraction of the grievances of each severity
+// in the report file, e.g. {testivus.Info: 0.1, testivus.Minor: 0.5}.
+// Severities that aren't listed are always kept. Sampled out grievances still
+// count towards every total in the summary, they just aren't listed one by
+// one.
+func SetSeveritySampling(rates map[Severity]float64) {
+	severitySampling = rates
+}
+
+// sample returns a copy of the disappointments with grievances sampled out by
+// severity. The summary is kept as is so the counts stay complete. The caller
+// must have filled in the summary.
+func (d *disappointments) sample() *disappointments {
+	if len(severitySampling) == 0 {
+		return d
+	}
+
+	// draw in a fixed order, so the same seed samples the same grievances
+	names := make([]string, 0, len(d.Grievances))
+	for name := range d.Grievances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	s := d.withoutGrievances()
+	for _, name := range names {
+		for _, g := range d.Grievances[name] {
+			rate, ok := severitySampling[d.Summary.severityOf(g)]
+			if ok && rng.Float64() >= rate {
+				s.Summary.SampledOut++
+				continue
+			}
+			s.Grievances[name] = append(s.Grievances[name], g)
+		}
+	}
+	return s
+}
+
+// SetSeed seeds the random number generator behind all of testivus' sampling,
+// so sampled runs can be reproduced. -testivus.seed does the same from the
+// command line.
+func SetSeed(s int64) {
+	seed = s
+	rng = rand.New(rand.NewSource(s))
+}
+
+// GrievanceSampled registers a disappointment with probability p, for chaos
+// and fuzz style instrumentation. Calls that aren't recorded are counted as
+// skipped in the summary. The returned disappointment is only stored if it
+// was sampled in.
+func GrievanceSampled(t testing.TB, p float64, msg string, tags ...string) Disappointment {
+	t.Helper()
+	running.Lock()
+	if rng.Float64() >= p {
+		running.skipped++
+		running.Unlock()
+		return newGrievance(t.Name(), msg, tags)
+	}
+	g := running.record(t, t.Name(), msg, tags)
+	running.Unlock()
+
+	registered(t, g)
+	return g
+}
+
+// withoutGrievances is a copy of the disappointments with everything but the
+// grievances, for writing a subset of them with the complete summary.
+func (d *disappointments) withoutGrievances() *disappointments {
+	c := newDisappointments(nil)
+	c.Summary = d.Summary
+	c.Commit = d.Commit
+	c.Failed = d.Failed
+	c.Start = d.Start
+	c.Seed = d.Seed
+	return c
+}
+
+// fit drops the least severe grievances until the JSON report is at most max
+// bytes, counting them as omitted in the summary. The summary is otherwise
+// kept as is so the counts stay complete. A max of zero or less keeps
+// everything. It is an error if the report is over max even without any
+// grievances.
+func (d *disappointments) fit(max int) (*disappointments, error) {
+	b, err := json.Marshal(d)
+	if err != nil || max <= 0 || len(b) <= max {
+		return d, err
+	}
+
+	type sized struct {
+		g    *disappointment
+		sev  Severity
+		seq  int
+		size int
+	}
+	names := make([]string, 0, len(d.Grievances))
+	for name := range d.Grievances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var all []sized
+	for _, name := range names {
+		for seq, g := range d.Grievances[name] {
+			gb, err := json.Marshal(g)
+			if err != nil {
+				return nil, err
+			}
+			all = append(all, sized{g: g, sev: d.Summary.severityOf(g), seq: seq, size: len(gb) + 1})
+		}
+	}
+	// least severe first, and within a test the latest first, so the same
+	// report is always trimmed the same way
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].sev != all[j].sev {
+			return all[i].sev < all[j].sev
+		}
+		if all[i].g.Name != all[j].g.Name {
+			return all[i].g.Name < all[j].g.Name
+		}
+		return all[i].seq > all[j].seq
+	})
+
+	// drop enough to cover the excess, then check again since the estimate
+	// ignores the separators and the omitted count itself
+	omit := make(map[*disappointment]bool)
+	excess := len(b) - max
+	for i := 0; i < len(all); {
+		for ; i < len(all) && excess > 0; i++ {
+			omit[all[i].g] = true
+			excess -= all[i].size
+		}
+
+		f := d.withoutGrievances()
+		f.Summary.Omitted = d.Summary.Omitted + len(omit)
+		for name, v := range d.Grievances {
+			for _, g := range v {
+				if !omit[g] {
+					f.Grievances[name] = append(f.Grievances[name], g)
+				}
+			}
+		}
+
+		if b, err = json.Marshal(f); err != nil || len(b) <= max {
+			return f, err
+		}
+		excess = len(b) - max
+	}
+	return nil, fmt.Errorf("report is %d bytes without any grievances, over the %d byte limit", len(b), max)
+}
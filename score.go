@@ -0,0 +1,92 @@
+package testivus
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+)
+
+// severityWeights are how much each severity adds to the score.
+var severityWeights = map[Severity]int{
+	Info:     0,
+	Minor:    1,
+	Major:    3,
+	Critical: 10,
+}
+
+// SetSeverityWeight sets how much a grievance of severity s adds to the score.
+func SetSeverityWeight(s Severity, w int) {
+	severityWeights[s] = w
+}
+
+type weightedTest struct {
+	re *regexp.Regexp
+	w  float64
+}
+
+var testWeights []weightedTest
+
+// SetTestWeight multiplies the score of grievances from tests whose name
+// matches the regular expression pattern by w, so the tests that matter most
+// stand out. The first matching pattern wins and other tests weigh 1. Once any
+// weight is set, By Test is ordered by score.
+func SetTestWeight(pattern string, w float64) error {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return fmt.Errorf("test weight: %v", err)
+	}
+	testWeights = append(testWeights, weightedTest{re: re, w: w})
+	return nil
+}
+
+// weighScore scales a grievance's score by the weight of its test.
+func weighScore(name string, score int) int {
+	for _, tw := range testWeights {
+		if tw.re.MatchString(name) {
+			return int(math.Round(float64(score) * tw.w))
+		}
+	}
+	return score
+}
+
+// sortRowsByScore orders rows highest score first, then by count and ID.
+func sortRowsByScore(rows []reportRow) {
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].Score > rows[j].Score })
+}
+
+var scoreTarget int
+
+// SetScoreTarget sets the score you are aiming to stay under. When it is set,
+// the report closes with a recommendation pointing at the biggest contributor.
+func SetScoreTarget(n int) {
+	scoreTarget = n
+}
+
+// Recommendation is the closing line of the report when a score target is set.
+func (s summary) Recommendation() string {
+	if scoreTarget <= 0 {
+		return ""
+	}
+	if s.Score <= scoreTarget {
+		return fmt.Sprintf("Score %d is within target %d.\n", s.Score, scoreTarget)
+	}
+
+	if tag, score := top(s.ScoreByTag); tag != "" {
+		return fmt.Sprintf("Score %d exceeds target %d, investigate top tag '%s' (score %d).\n", s.Score, scoreTarget, tag, score)
+	}
+	name, score := top(s.ScoreByName)
+	return fmt.Sprintf("Score %d exceeds target %d, investigate top test '%s' (score %d).\n", s.Score, scoreTarget, name, score)
+}
+
+// top returns the key with the highest value, breaking ties by key.
+func top(m map[string]int) (string, int) {
+	var key string
+	var max int
+	for k, v := range m {
+		if key == "" || v > max || (v == max && k < key) {
+			key, max = k, v
+		}
+	}
+	return key, max
+}
@@ -0,0 +1,84 @@
+package testivus
+
+import (
+	"fmt"
+	"testing"
+)
+
+type section struct {
+	name  string
+	count int
+}
+
+// Section scopes grievances to a named operation within a test. Every
+// grievance t registers until end is called is tagged section:name, and if
+// there were any, end registers one more summing them up. Sections nest, and
+// a grievance is tagged with every section it is inside.
+//
+//	end := testivus.Section(t, "migrate")
+//	defer end()
+func Section(t testing.TB, name string) (end func()) {
+	running.Lock()
+	defer running.Unlock()
+
+	s := &section{name: name}
+	running.sections[t.Name()] = append(running.sections[t.Name()], s)
+
+	return func() {
+		t.Helper()
+		running.Lock()
+		g := running.endSection(t, s)
+		running.Unlock()
+
+		if g != nil {
+			registered(t, g)
+		}
+	}
+}
+
+// endSection closes s, registering the grievance summing it up if it had
+// any. The caller must hold the lock.
+func (d *disappointments) endSection(t testing.TB, s *section) *disappointment {
+	t.Helper()
+
+	stack := d.sections[t.Name()]
+	for i := len(stack) - 1; i >= 0; i-- {
+		if stack[i] == s {
+			d.sections[t.Name()] = append(stack[:i:i], stack[i+1:]...)
+			break
+		}
+	}
+
+	if s.count == 0 {
+		return nil
+	}
+	g := d.record(t, t.Name(), fmt.Sprintf("section %s had %d grievances", s.name, s.count), []string{sectionTag(s.name)})
+
+	// the summing up is tagged with the enclosing sections but isn't another
+	// grievance of theirs
+	for _, outer := range d.sections[t.Name()] {
+		outer.count--
+	}
+	return g
+}
+
+func sectionTag(name string) string {
+	return "section:" + name
+}
+
+// enterSections tags g with every open section of its test and counts it
+// against them. The caller must hold the lock.
+func (d *disappointments) enterSections(g *disappointment) {
+	for _, s := range d.sections[g.Name] {
+		s.count++
+
+		tag := sectionTag(s.name)
+		found := false
+		for _, t := range g.Tags {
+			found = found || t == tag
+		}
+		if !found {
+			g.Tags = append(g.Tags, tag)
+		}
+	}
+}
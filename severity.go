@@ -0,0 +1,277 @@
+package testivus
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Severity is how badly your code has let you down.
+type Severity int
+
+// Severities from least to most disappointing. A grievance without an explicit
+// severity is Minor.
+const (
+	Info Severity = iota + 1
+	Minor
+	Major
+	Critical
+)
+
+var severityNames = map[Severity]string{
+	Info:     "Info",
+	Minor:    "Minor",
+	Major:    "Major",
+	Critical: "Critical",
+}
+
+// String returns the name of the severity.
+func (s Severity) String() string {
+	if n, ok := severityNames[s]; ok {
+		return n
+	}
+	return severityNames[Minor]
+}
+
+// ParseSeverity looks up a severity by name, ignoring case.
+func ParseSeverity(name string) (Severity, error) {
+	for s, n := range severityNames {
+		if strings.EqualFold(n, name) {
+			return s, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown severity %q", name)
+}
+
+// MarshalJSON renders the severity by name
+func (s Severity) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.String())
+}
+
+// UnmarshalJSON reads a severity by name
+func (s *Severity) UnmarshalJSON(b []byte) error {
+	var name string
+	if err := json.Unmarshal(b, &name); err != nil {
+		return err
+	}
+
+	v, err := ParseSeverity(name)
+	if err != nil {
+		return err
+	}
+	*s = v
+	return nil
+}
+
+// severityFiles maps severities to the files their grievances are written to.
+type severityFiles map[Severity][]string
+
+func (f severityFiles) String() string {
+	var parts []string
+	for s, paths := range f {
+		for _, p := range paths {
+			parts = append(parts, s.String()+":"+p)
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+func (f severityFiles) Set(v string) error {
+	i := strings.Index(v, ":")
+	if i < 1 || i == len(v)-1 {
+		return fmt.Errorf("expected severity:path, got %q", v)
+	}
+
+	s, err := ParseSeverity(v[:i])
+	if err != nil {
+		return err
+	}
+	f[s] = append(f[s], v[i+1:])
+	return nil
+}
+
+type escalation struct {
+	count int
+	to    Severity
+}
+
+type escalationRow struct {
+	Tag      string
+	Count    int
+	Limit    int
+	Severity Severity
+}
+
+var escalations = map[string]escalation{}
+
+// SetEscalation treats grievances tagged with tag as at least severity to once
+// the tag shows up more than count times in the run. Repeated small problems
+// become a big one.
+func SetEscalation(tag string, count int, to Severity) {
+	escalations[tag] = escalation{count: count, to: to}
+}
+
+// downgradePattern matches the grievances -testivus.downgrade treats as Info.
+var downgradePattern *regexp.Regexp
+
+// downgraded reports whether g's message or one of its tags matches
+// -testivus.downgrade.
+func downgraded(g *disappointment) bool {
+	if downgradePattern == nil {
+		return false
+	}
+	if downgradePattern.MatchString(g.Message) {
+		return true
+	}
+	for _, t := range g.Tags {
+		if downgradePattern.MatchString(t) {
+			return true
+		}
+	}
+	return false
+}
+
+type errorSeverity struct {
+	target error
+	s      Severity
+}
+
+var errorSeverities []errorSeverity
+
+// RegisterErrorSeverity infers severity s for grievances whose error matches
+// target with errors.Is, unless they were given a severity explicitly. The
+// first matching registration wins.
+func RegisterErrorSeverity(target error, s Severity) {
+	errorSeverities = append(errorSeverities, errorSeverity{target: target, s: s})
+}
+
+var severityClassifier func(Disappointment) Severity
+
+// SetSeverityClassifier lets classify decide the severity of every grievance
+// as it is registered, to keep severity policy in one place instead of in
+// WithSeverity calls. It can go by the grievance's String, with its message
+// and tags, or its Key, which adds the test name, but doesn't see what is
+// added by chained With calls. The severity it returns counts as set
+// explicitly, while zero leaves the grievance to error inference and the
+// Minor default. It is called with the lock held, so it must not register
+// grievances itself. Passing nil removes the classifier.
+func SetSeverityClassifier(classify func(Disappointment) Severity) {
+	severityClassifier = classify
+}
+
+// classify sets g's severity with the severity classifier, if there is one.
+func classify(g *disappointment) {
+	if severityClassifier != nil && g.Severity == 0 {
+		g.held = true
+		g.Severity = severityClassifier(g)
+		g.held = false
+	}
+}
+
+// inferSeverity is the severity of a grievance, inferred from its error if it
+// wasn't set explicitly.
+func inferSeverity(g *disappointment) Severity {
+	if g.Severity == 0 && g.Error != nil {
+		for _, es := range errorSeverities {
+			if errors.Is(g.Error, es.target) {
+				return es.s
+			}
+		}
+	}
+	return g.severity()
+}
+
+// abortIfCritical stops the test that registered g if it is Critical, going
+// by its severity, error and deadline, and -testivus.abortoncritical is set.
+// It also turns on go test's -failfast for the rest of the run, so no further
+// tests are started. Grievances without a test, such as global ones, are left
+// alone. It doesn't return, so it does nothing while hooks or the classifier
+// run on g with the lock held; the caller that registered g checks again
+// once the lock is released.
+func abortIfCritical(g *disappointment) {
+	if !*abortCritical || g.t == nil || g.held || belowMinSeverity(g) || downgraded(g) {
+		return
+	}
+	if !g.overdue() && inferSeverity(g) != Critical {
+		return
+	}
+
+	g.t.Helper()
+	if err := flag.Set("test.failfast", "true"); err != nil {
+		g.t.Logf("testivus: could not stop further tests: %v", err)
+	}
+	g.t.Fatalf("testivus: aborting on Critical grievance: %s", g)
+}
+
+// severityOf is the effective severity of a grievance after inference and
+// escalation. Downgraded grievances are always Info, and other overdue
+// grievances are always Critical.
+func (s summary) severityOf(g *disappointment) Severity {
+	if downgraded(g) {
+		return Info
+	}
+	if g.overdue() {
+		return Critical
+	}
+
+	sev := inferSeverity(g)
+	for _, t := range s.tags(g) {
+		if e, ok := s.Escalated[t]; ok && e > sev {
+			sev = e
+		}
+	}
+	return sev
+}
+
+var minSeverity Severity
+
+// SetMinSeverity drops grievances below s as they are registered, instead of
+// storing them. The summary only counts how many were suppressed. Severity is
+// checked when a grievance is registered and again after WithSeverity is
+// called on it, so a grievance raised to s later is stored, and one lowered
+// below s is dropped, by the time the report or Sequence looks. The default,
+// zero, records everything.
+func SetMinSeverity(s Severity) {
+	minSeverity = s
+}
+
+// belowMinSeverity reports whether g should be suppressed.
+func belowMinSeverity(g *disappointment) bool {
+	return minSeverity > 0 && inferSeverity(g) < minSeverity
+}
+
+// recheck stores or suppresses the grievances whose severity changed since
+// they were added, including those changed by hooks as they are stored. The
+// caller must hold the lock.
+func (d *disappointments) recheck() {
+	for {
+		d.rechecksMu.Lock()
+		rechecks := d.rechecks
+		d.rechecks = nil
+		d.rechecksMu.Unlock()
+		if len(rechecks) == 0 {
+			return
+		}
+
+		for _, g := range rechecks {
+			d.resuppress(g)
+		}
+	}
+}
+
+// resuppress stores or suppresses g after its severity changed. The caller
+// must hold the lock.
+func (d *disappointments) resuppress(g *disappointment) {
+	switch {
+	case g.suppressed && !belowMinSeverity(g):
+		g.suppressed = false
+		d.suppressed--
+		d.add(nil, g)
+	case !g.suppressed && belowMinSeverity(g) && d.remove(g):
+		g.suppressed = true
+		d.suppressed++
+	}
+}
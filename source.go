@@ -0,0 +1,76 @@
+package testivus
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// packagePrefix is the prefix of every function in this package.
+var packagePrefix = func() string {
+	pc, _, _, _ := runtime.Caller(0)
+	return funcPackage(runtime.FuncForPC(pc).Name()) + "."
+}()
+
+// funcPackage is the import path of the package a function belongs to, taken
+// from its fully qualified name.
+func funcPackage(name string) string {
+	slash := strings.LastIndex(name, "/")
+	if dot := strings.Index(name[slash+1:], "."); dot >= 0 {
+		return name[:slash+1+dot]
+	}
+	return name
+}
+
+// callerFrames returns the stack above testivus, starting with the frame that
+// called into it.
+func callerFrames() []runtime.Frame {
+	pcs := make([]uintptr, 32)
+	n := runtime.Callers(2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var stack []runtime.Frame
+	inside := true
+	for {
+		f, more := frames.Next()
+		if inside && (!strings.HasPrefix(f.Function, packagePrefix) || strings.HasSuffix(f.File, "_test.go")) {
+			inside = false
+		}
+		if !inside {
+			stack = append(stack, f)
+		}
+		if !more {
+			break
+		}
+	}
+	return stack
+}
+
+// sourceFile shortens path relative to the working directory when it can.
+func sourceFile(path string) string {
+	wd, err := os.Getwd()
+	if err != nil {
+		return path
+	}
+	if rel, err := filepath.Rel(wd, path); err == nil && !strings.HasPrefix(rel, "..") {
+		return rel
+	}
+	return path
+}
+
+// sourcePackage is the package of the first frame in stack that belongs to the
+// code under test rather than to a test file, testivus or the test runner.
+func sourcePackage(stack []runtime.Frame) string {
+	for _, f := range stack {
+		if strings.HasSuffix(f.File, "_test.go") || strings.HasPrefix(f.Function, packagePrefix) {
+			continue
+		}
+		switch pkg := funcPackage(f.Function); pkg {
+		case "testing", "runtime":
+		default:
+			return pkg
+		}
+	}
+	return ""
+}
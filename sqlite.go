@@ -0,0 +1,104 @@
+//go:build sqlite
+
+package testivus
+
+import (
+	"database/sql"
+	"flag"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	_ "modernc.org/sqlite" // pure Go driver, registered as "sqlite"
+)
+
+func init() {
+	fileFlags["sqlite"] = flag.String("testivus.sqlitefile", "", "write grievances to a SQLite database for querying")
+	outputWriters["sqlite"] = writeSQLite
+}
+
+const sqliteSchema = `CREATE TABLE grievances (
+	test     TEXT NOT NULL,
+	message  TEXT NOT NULL,
+	tags     TEXT NOT NULL,
+	error    TEXT,
+	severity TEXT NOT NULL,
+	time     TEXT NOT NULL
+)`
+
+// writeSQLite saves the grievances to a SQLite database at path, one row per
+// grievance in the grievances table. Tags are comma separated, severity is
+// the effective severity after inference and escalation, and time is when the
+// grievance was registered. Build with -tags sqlite.
+func writeSQLite(path string, d *disappointments) error {
+	// SQLite needs a file to build the database in, which is then written
+	// out like the other outputs, so it can be uploaded too
+	f, err := os.CreateTemp("", "testivus-*.sqlite")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	defer os.Remove(tmp)
+	// SQLite takes an empty file as a new database
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := buildSQLite(tmp, d); err != nil {
+		return err
+	}
+
+	return writeFile(path, func(w io.Writer) error {
+		db, err := os.Open(tmp)
+		if err != nil {
+			return err
+		}
+		defer db.Close()
+		_, err = io.Copy(w, db)
+		return err
+	})
+}
+
+// buildSQLite fills the empty database at path with the grievances.
+func buildSQLite(path string, d *disappointments) error {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return errors.Wrap(err, "open sqlite")
+	}
+	defer db.Close()
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		return errors.Wrap(err, "create grievances table")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO grievances (test, message, tags, error, severity, time) VALUES (?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, v := range d.Grievances {
+		for _, g := range v {
+			var e sql.NullString
+			if g.Error != nil {
+				e = sql.NullString{String: g.Error.Error(), Valid: true}
+			}
+			sev := d.Summary.severityOf(g).String()
+			if _, err := stmt.Exec(g.Name, g.Message, strings.Join(g.Tags, ","), e, sev, g.Time.UTC().Format(time.RFC3339Nano)); err != nil {
+				return errors.Wrap(err, "insert grievance")
+			}
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	return db.Close()
+}
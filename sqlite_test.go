@@ -0,0 +1,87 @@
+//go:build sqlite
+
+package testivus
+
+import (
+	"bytes"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteSQLite(t *testing.T) {
+	d := newDisappointments(nil)
+	d.record(nil, "TestA", "You're slow!", []string{"speed", "download"}).WithError(errors.New("timeout exceeded"))
+	d.record(nil, "TestB", "My son tells me your company stinks!", nil).WithSeverity(Critical)
+	d.Summary = d.summarize()
+
+	path := filepath.Join(t.TempDir(), "report.sqlite")
+	if err := writeSQLite(path, d); err != nil {
+		t.Fatal(err)
+	}
+
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rows, err := db.Query(`SELECT test, message, tags, error, severity FROM grievances ORDER BY test`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+
+	var got [][5]string
+	for rows.Next() {
+		var row [5]string
+		var e sql.NullString
+		if err := rows.Scan(&row[0], &row[1], &row[2], &e, &row[4]); err != nil {
+			t.Fatal(err)
+		}
+		row[3] = e.String
+		got = append(got, row)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := [][5]string{
+		{"TestA", "You're slow!", "speed,download", "timeout exceeded", "Minor"},
+		{"TestB", "My son tells me your company stinks!", "", "", "Critical"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("rows = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("row %d = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
+
+func TestUploadSQLite(t *testing.T) {
+	saved, ok := uploaders["s3"]
+	defer func() {
+		if delete(uploaders, "s3"); ok {
+			uploaders["s3"] = saved
+		}
+	}()
+
+	var got []byte
+	uploaders["s3"] = func(url string, data []byte) error {
+		got = data
+		return nil
+	}
+
+	d := newDisappointments(nil)
+	d.record(nil, "TestA", "You're slow!", []string{"speed"})
+	d.Summary = d.summarize()
+	if err := writeSQLite("s3://reports/run.sqlite", d); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasPrefix(got, []byte("SQLite format 3\x00")) {
+		t.Errorf("uploaded %d bytes that aren't a SQLite database", len(got))
+	}
+}
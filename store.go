@@ -0,0 +1,122 @@
+package testivus
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// Store is where grievances are kept while the tests run. The default keeps
+// them in memory; a custom store can persist them elsewhere, such as to a
+// database, for suites too large to hold every grievance at once.
+type Store interface {
+	// Add keeps a grievance.
+	Add(d Disappointment)
+	// Range calls fn for each grievance kept, stopping early if fn returns
+	// false.
+	Range(fn func(d Disappointment) bool)
+}
+
+var store Store
+
+// SetStore keeps grievances in s instead of in memory. Call it before Run. The
+// report and checkpoints are built by ranging over s, so s must give back the
+// grievances it was given, either as is or rebuilt with UnmarshalGrievance.
+// Grievances it gives back as anything else are left out of the report, and a
+// grievance of testivus itself says how many.
+//
+// AssertNoNewGrievances, SetPerTestLimit, Checkpoint and Load work with any
+// store. The rest only see grievances kept in memory, so with a custom store
+// Sequence returns nothing, Retract reports false, and a grievance whose
+// severity drops below SetMinSeverity after it was stored stays stored.
+func SetStore(s Store) {
+	store = s
+}
+
+// MarshalGrievance encodes a grievance for a store to persist, such as in a
+// database. It is the grievance as it appears in the JSON report.
+func MarshalGrievance(d Disappointment) ([]byte, error) {
+	return json.Marshal(d)
+}
+
+// UnmarshalGrievance rebuilds a grievance encoded by MarshalGrievance, for a
+// store to give back from Range.
+func UnmarshalGrievance(b []byte) (Disappointment, error) {
+	g := &disappointment{}
+	if err := unmarshal(b, g); err != nil {
+		return nil, errors.Wrap(err, "decode grievance")
+	}
+	return g, nil
+}
+
+// memoryStore keeps grievances in the collector's map, keyed by test name.
+type memoryStore struct {
+	d *disappointments
+}
+
+func (m memoryStore) Add(d Disappointment) {
+	g := d.(*disappointment)
+	m.d.Grievances[g.Name] = append(m.d.Grievances[g.Name], g)
+}
+
+func (m memoryStore) Range(fn func(d Disappointment) bool) {
+	names := make([]string, 0, len(m.d.Grievances))
+	for name := range m.d.Grievances {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		for _, g := range m.d.Grievances[name] {
+			if !fn(g) {
+				return
+			}
+		}
+	}
+}
+
+// collect reads the grievances back from a custom store so the report can be
+// built. It does nothing for the memory store.
+func (d *disappointments) collect() {
+	d.recheck()
+	if _, ok := d.store.(memoryStore); ok {
+		return
+	}
+
+	if unreadable := d.fromStore(d); unreadable > 0 {
+		g := newGrievance("testivus", "store gave back grievances testivus can't read", []string{"testivus"})
+		g.Error = fmt.Errorf("%d grievances left out of the report; rebuild them with UnmarshalGrievance", unreadable)
+		g.Severity = Major
+		d.Grievances[g.Name] = append(d.Grievances[g.Name], g)
+	}
+}
+
+// fromStore adds the grievances in d's store to c, returning how many were
+// given back as something other than a grievance.
+func (d *disappointments) fromStore(c *disappointments) int {
+	unreadable := 0
+	d.store.Range(func(v Disappointment) bool {
+		if g, ok := v.(*disappointment); ok {
+			c.Grievances[g.Name] = append(c.Grievances[g.Name], g)
+		} else {
+			unreadable++
+		}
+		return true
+	})
+	return unreadable
+}
+
+// withStored is d with the grievances from a custom store in place of the
+// ones in memory, for saving. It is d itself for the memory store. The caller
+// must hold the lock.
+func (d *disappointments) withStored() *disappointments {
+	if _, ok := d.store.(memoryStore); ok {
+		return d
+	}
+
+	c := d.withoutGrievances()
+	d.fromStore(c)
+	return c
+}
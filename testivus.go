@@ -7,20 +7,62 @@ package testivus
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	stderrors "errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"regexp"
+	"runtime"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"testing"
 	"text/tabwriter"
+	"text/template"
+	"time"
 
 	"github.com/pkg/errors"
 )
 
-var reportFile = flag.String("testivus.outputfile", "", "write a detailed disappointment report to a file")
+var (
+	reportFile     = flag.String("testivus.outputfile", "", "write a detailed disappointment report to a file, or to an s3:// or gs:// URL when built with the s3 or gcs tag")
+	testLog        = flag.Bool("testivus.testlog", false, "log grievances with t.Log instead of printing them")
+	captureSource  = flag.Bool("testivus.source", false, "record the file and line each grievance was registered from")
+	platformTags   = flag.Bool("testivus.platformtags", false, "tag every grievance with the current OS and architecture")
+	format         = flag.String("testivus.format", "text", "how to print the report: text, compact, severity-tag or boxed")
+	barMetric      = flag.String("testivus.barmetric", "count", "what drives the length of the report's bars: count or score")
+	golden         = flag.Bool("testivus.golden", false, "print the report in the stable golden format")
+	showErrorChain = flag.Bool("testivus.errorchain", false, "show the chain of wrapped errors under each error in the verbose report")
+	foldCase       = flag.Bool("testivus.foldcase", false, "count tags that differ only in case or surrounding space as one")
+	untaggedBucket = flag.String("testivus.untaggedbucket", "", "count grievances without tags under this tag, so By Tag adds up to the total")
+	maxTags        = flag.Int("testivus.maxtags", 0, "warn when there are more than this many distinct tags (0 means no limit)")
+	ciOnly         = flag.Bool("testivus.cionly", false, "only let the exit policy change the exit code when running in CI")
+	checkpointFile = flag.String("testivus.checkpoint", "", "resume from this checkpoint file if it exists, and remove it when the run finishes")
+	outputSpec     = flag.String("testivus.outputs", "", "write the report in several formats, as `format:path,...`")
+	padding        = flag.Int("testivus.padding", 1, "spaces between the columns of the report")
+	minWidth       = flag.Int("testivus.minwidth", 0, "minimum width of the report's columns, including padding")
+	timelineFormat = flag.String("testivus.timeline", "", "list grievances in the order they were registered in the verbose report, with relative or absolute times")
+	downgrade      = flag.String("testivus.downgrade", "", "treat grievances whose message or a tag matches this regular expression as Info")
+	eventsFile     = flag.String("testivus.events", "", "write run start, grievance and run end events to a file as JSON lines")
+	githubSummary  = flag.Bool("testivus.githubsummary", false, "in GitHub Actions, add the report to the job summary and annotate grievances with source locations")
+	seedFlag       = flag.Int64("testivus.seed", 0, "seed sampling with this, to reproduce a run whose report shows it (0 picks one)")
+	maxFileSize    = flag.Float64("testivus.maxfilesize", 0, "keep the JSON report under this many megabytes by leaving out the least severe grievances (0 means no limit)")
+	strictDecode   = flag.Bool("testivus.strictdecode", false, "reject reports and checkpoints with fields testivus doesn't know, to catch incompatible tooling")
+	abortCritical  = flag.Bool("testivus.abortoncritical", false, "stop a test at its first Critical grievance, and set -test.failfast so no new tests start")
+	liveFormat     = flag.String("testivus.liveformat", "default", "how to print grievances as they happen: default or prefix, as [Test][Severity] message")
+	severityOut    = severityFiles{}
+
+	outputs []output
+)
+
+func init() {
+	flag.Var(severityOut, "testivus.severityfile", "write grievances of one severity to a file, as `severity:path` (repeatable)")
+}
 
 // Disappointments are all the ways your code has let you down without
 // explicitly failing.
@@ -28,26 +70,88 @@ type disappointments struct {
 	sync.Mutex `json:"-"`
 	Grievances map[string][]*disappointment `json:"grievances"`
 	Summary    summary                      `json:"summary"`
+	Commit     string                       `json:"commit,omitempty"`
+	Failed     map[string]bool              `json:"failed,omitempty"`
+	Start      time.Time                    `json:"start,omitzero"`
+	Seed       int64                        `json:"seed,omitempty"`
+
+	once     map[string]*disappointment
+	tests    map[string]bool
+	dropped  map[string]int
+	stored   map[string]int
+	sections map[string][]*section
+	parallel map[string]bool
+	skipped  int
+	store    Store
+
+	suppressed int
+	// rechecks are grievances whose severity changed after they were added,
+	// waiting for recheck. They have their own lock, as WithSeverity may be
+	// called with d locked, from a hook or the classifier.
+	rechecksMu sync.Mutex
+	rechecks   []*disappointment
+
+	recent     []*disappointment
+	recentNext int
 }
 
 // Summary is an aggregation of all your disappointments
 type summary struct {
-	Total   int
-	ByName  map[string]int
-	ByTag   map[string]int
-	ByError map[string]int
+	Commit      string
+	Seed        int64
+	Total       int
+	Tests       int
+	ByName      map[string]int
+	ByTag       map[string]int
+	TagLinks    map[string]string
+	ByError     map[string]int
+	BySeverity  map[string]int
+	ByFile      map[string]int
+	ByPackage   map[string]int
+	ByFuzz      map[string]int
+	ByDimension map[string]map[string]int
+	TagPairs    map[string]map[string]int
+	Escalated   map[string]Severity
+	TagsByTest  map[string]map[string]int
+	Duplicates  map[string]int
+	SampledOut  int
+	Omitted     int
+	Skipped     int
+	Suppressed  int
+	Dropped     map[string]int
+	Score       int
+	ScoreByTag  map[string]int
+	ScoreByName map[string]int
+	Latency     map[string]Latency
+	Failed      map[string]bool
+	Overdue     int
 
-	nameRows  []reportRow
-	tagRows   []reportRow
-	errorRows []reportRow
+	nameRows       []reportRow
+	tagRows        []reportRow
+	errorRows      []reportRow
+	dimensionRows  []dimensionRows
+	pairRows       []reportRow
+	escalationRows []escalationRow
+	errorChains    map[string][]string
+	errorExamples  map[string]string
+	duplicateRows  []reportRow
+	tagNames       map[string]string
+	droppedRows    []reportRow
+	fileRows       []reportRow
+	packageRows    []reportRow
+	fuzzRows       []reportRow
+	overdueRows    []overdueRow
+	timeline       []timelineRow
 }
 
 // MarshalJSON renders the summary to JSON
 func (s summary) MarshalJSON() ([]byte, error) {
 	m := map[string]interface{}{
-		"total":  s.Total,
-		"byTag":  s.ByTag,
-		"byName": s.ByName,
+		"total":      s.Total,
+		"tests":      s.Tests,
+		"byTag":      s.ByTag,
+		"byName":     s.ByName,
+		"bySeverity": s.BySeverity,
 	}
 
 	if len(s.ByError) > 0 {
@@ -58,9 +162,91 @@ func (s summary) MarshalJSON() ([]byte, error) {
 		m["byError"] = be
 	}
 
+	if len(s.TagLinks) > 0 {
+		m["tagLinks"] = s.TagLinks
+	}
+
+	if len(s.ByFile) > 0 {
+		m["byFile"] = s.ByFile
+	}
+
+	if len(s.ByFuzz) > 0 {
+		m["byFuzzTarget"] = s.ByFuzz
+	}
+
+	if len(s.ByPackage) > 0 {
+		m["byPackage"] = s.ByPackage
+	}
+
+	if len(s.ByDimension) > 0 {
+		m["byDimension"] = s.ByDimension
+	}
+
+	if len(s.TagPairs) > 0 {
+		m["tagPairs"] = s.TagPairs
+	}
+
+	if len(s.Escalated) > 0 {
+		m["escalated"] = s.Escalated
+	}
+
+	if len(s.TagsByTest) > 0 {
+		m["tagsByTest"] = s.TagsByTest
+	}
+
+	if len(s.Duplicates) > 0 {
+		m["duplicates"] = s.Duplicates
+	}
+
+	if len(s.Dropped) > 0 {
+		m["dropped"] = s.Dropped
+	}
+
+	if s.Skipped > 0 {
+		m["skipped"] = s.Skipped
+	}
+
+	if s.Overdue > 0 {
+		m["overdue"] = s.Overdue
+	}
+
+	if s.Suppressed > 0 {
+		m["suppressed"] = s.Suppressed
+	}
+
+	if s.SampledOut > 0 {
+		m["sampledOut"] = s.SampledOut
+	}
+
+	if s.Omitted > 0 {
+		m["omitted"] = s.Omitted
+	}
+
+	if len(s.Latency) > 0 {
+		m["latency"] = s.Latency
+	}
+
+	m["score"] = s.Score
+	m["scoreByTag"] = s.ScoreByTag
+
 	return json.Marshal(m)
 }
 
+// UnmarshalJSON reads a summary from JSON
+func (s *summary) UnmarshalJSON(b []byte) error {
+	type plain summary
+	v := struct {
+		*plain
+		ByFuzzTarget map[string]int `json:"byFuzzTarget"`
+	}{plain: (*plain)(s)}
+
+	if err := unmarshal(b, &v); err != nil {
+		return err
+	}
+	s.ByFuzz = v.ByFuzzTarget
+	return nil
+}
+
 // String renders a text representation of your disappointments for the
 // airing of grievances.
 func (d *disappointments) String() string {
@@ -68,38 +254,102 @@ func (d *disappointments) String() string {
 	defer d.Unlock()
 
 	s := d.summarize()
+	if customTemplate != nil {
+		return render(customTemplate, s)
+	}
+
 	if s.Total == 0 {
+		if verbose() {
+			return fmt.Sprintf("0 disappointments across %d tests, you are truly master of your domain.\n", s.Tests)
+		}
 		return "No disapointments, you are truly master of your domain.\n"
-	} else if !testing.Verbose() {
-		return fmt.Sprintf("I got a lot of problems with you people! (%d disappointments)\n", s.Total)
+	} else if !verbose() {
+		return fmt.Sprintf("I got a lot of problems with you people! (%d disappointments)\n", s.Total) + s.Recommendation()
 	}
 
-	var buf bytes.Buffer
-	w := tabwriter.NewWriter(&buf, 0, 0, 1, ' ', 0)
-	fmt.Fprintf(w, "\n=== The airing of grievances:\n")
-	fmt.Fprintf(w, "I got a lot of problems with you people! (%d disappointments)\n", s.Total)
+	return render(defaultTemplate, s)
+}
 
-	if len(s.tagRows) > 0 {
-		fmt.Fprintf(w, "\nBy Tag:\n")
-		for _, r := range s.tagRows {
-			fmt.Fprintf(w, "\t%s\t%d\t%s\n", r.ID, r.Count, strings.Repeat("|", r.Count))
-		}
-	}
-	w.Flush()
+// DefaultTemplate is the text/template used to air your grievances in verbose
+// mode. It is fed the summary of your disappointments and may be used as a
+// starting point for your own template with SetTemplate.
+const DefaultTemplate = `{{define "rows"}}{{range .}}	{{.ID}}	{{.Numbers}}	{{bar .Length}}{{with .Link}} → {{.}}{{end}}
+{{end}}{{end}}
+=== The airing of grievances:
+I got a lot of problems with you people! ({{.Total}} disappointments across {{.Tests}} tests)
+{{with .Commit}}Commit: {{.}}
+{{end}}{{with .Seed}}Seed: {{.}}
+{{end}}{{if .TagRows}}
+By Tag:
+{{template "rows" .TagRows}}{{end}}{{if .PairRows}}
+Tags Together:
+{{template "rows" .PairRows}}{{end}}{{if .ErrorRows}}
+By Error:
+{{range .ErrorRows}}	{{.ID}}	{{.Numbers}}	{{bar .Length}}
+{{with $.ErrorExample .ID}}	  e.g. {{.}}
+{{end}}{{range $.ErrorChain .ID}}	  caused by: {{.}}
+{{end}}{{end}}{{end}}{{if .DuplicateRows}}
+Duplicates:
+{{template "rows" .DuplicateRows}}{{end}}{{if .OverdueRows}}
+Overdue:
+{{range .OverdueRows}}	{{.Grievance}}	{{.Test}}	due {{.Deadline.Format "2006-01-02"}}
+{{end}}{{end}}{{if .Escalations}}
+Escalated:
+{{range .Escalations}}	{{.Tag}}	{{.Count}} > {{.Limit}}	now {{.Severity}}
+{{end}}{{end}}{{range .Dimensions}}
+By {{.Name}}:
+{{template "rows" .Rows}}{{end}}{{if .FileRows}}
+By File:
+{{template "rows" .FileRows}}{{end}}{{if .PackageRows}}
+By Source Package:
+{{template "rows" .PackageRows}}{{end}}{{if .FuzzRows}}
+By Fuzz Target:
+{{template "rows" .FuzzRows}}{{end}}
+By Test:
+{{template "rows" .NameRows}}{{if .Timeline}}
+Timeline:
+{{range .Timeline}}	{{.At}}	{{.Test}}	{{.Grievance}}
+{{end}}{{end}}{{if .FailedTests}}
+Failed Anyway:
+{{range .FailedTests}}	{{.}}
+{{end}}{{end}}{{if .DroppedRows}}
+Dropped Over Limit:
+{{template "rows" .DroppedRows}}{{end}}
+{{with .Recommendation}}{{.}}
+{{end}}`
 
-	if len(s.errorRows) > 0 {
-		fmt.Fprintf(w, "\nBy Error:\n")
-		for _, r := range s.errorRows {
-			fmt.Fprintf(w, "\t%s\t%d\t%s\n", r.ID, r.Count, strings.Repeat("|", r.Count))
-		}
-	}
-	w.Flush()
+// TemplateFuncs are the functions available to DefaultTemplate. Add them to
+// your own template if you extend it.
+var TemplateFuncs = template.FuncMap{
+	"bar":      func(n int) string { return strings.Repeat("|", n) },
+	"duration": formatDuration,
+}
+
+var (
+	defaultTemplate = template.Must(template.New("testivus").Funcs(TemplateFuncs).Parse(DefaultTemplate))
+	customTemplate  *template.Template
+)
+
+// SetTemplate replaces the report with your own text/template. The template is
+// fed the summary of your disappointments and is used for all output,
+// regardless of verbosity. Passing nil restores the default report.
+func SetTemplate(tmpl *template.Template) {
+	customTemplate = tmpl
+}
+
+// newTabWriter aligns the report's tab separated columns, spaced by
+// -testivus.padding and -testivus.minwidth.
+func newTabWriter(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, *minWidth, 0, *padding, ' ', 0)
+}
 
-	fmt.Fprintf(w, "\nBy Test:\n")
-	for _, r := range s.nameRows {
-		fmt.Fprintf(w, "\t%s\t%d\t%s\n", r.ID, r.Count, strings.Repeat("|", r.Count))
+// render executes tmpl with the summary, aligning tab separated columns.
+func render(tmpl *template.Template, s summary) string {
+	var buf bytes.Buffer
+	w := newTabWriter(&buf)
+	if err := tmpl.Execute(w, s); err != nil {
+		return fmt.Sprintf("could not render report: %v\n", err)
 	}
-	fmt.Fprintf(w, "\n")
 	w.Flush()
 
 	return buf.String()
@@ -108,10 +358,152 @@ func (d *disappointments) String() string {
 type reportRow struct {
 	ID    string
 	Count int
+	Score int
+	Link  string
+
+	scored bool
+}
+
+// Numbers are the figures shown for the row: the count, and also the score
+// when -testivus.barmetric=score and the row is scored.
+func (r reportRow) Numbers() string {
+	if *barMetric == "score" && r.scored {
+		return fmt.Sprintf("%d (score %d)", r.Count, r.Score)
+	}
+	return strconv.Itoa(r.Count)
+}
+
+// Length is how long the row's bar is, by count or by score depending on
+// -testivus.barmetric. Rows without a score always use the count.
+func (r reportRow) Length() int {
+	if *barMetric == "score" && r.scored {
+		return r.Score
+	}
+	return r.Count
+}
+
+// sortRows orders rows most disappointing first, breaking ties by ID so the
+// report is stable from run to run.
+func sortRows(rows []reportRow) {
+	sort.Slice(rows, func(i, j int) bool {
+		if rows[i].Count != rows[j].Count {
+			return rows[i].Count > rows[j].Count
+		}
+		return rows[i].ID < rows[j].ID
+	})
+}
+
+// TagRows are the tag counts, most disappointing first.
+func (s summary) TagRows() []reportRow { return s.tagRows }
+
+// ErrorRows are the error counts, most disappointing first.
+func (s summary) ErrorRows() []reportRow { return s.errorRows }
+
+// NameRows are the test counts, most disappointing first.
+func (s summary) NameRows() []reportRow { return s.nameRows }
+
+// PairRows are the tag pairs that show up together most often.
+func (s summary) PairRows() []reportRow {
+	if len(s.pairRows) > maxPairRows {
+		return s.pairRows[:maxPairRows]
+	}
+	return s.pairRows
+}
+
+// maxPairRows is how many tag pairs are shown in the report.
+const maxPairRows = 5
+
+func pairCount(m map[string]map[string]int, a, b string) {
+	if m[a] == nil {
+		m[a] = make(map[string]int)
+	}
+	m[a][b]++
+}
+
+// ErrorChain is the chain of errors wrapped by the error with message msg, when
+// -testivus.errorchain is on.
+func (s summary) ErrorChain(msg string) []string {
+	if !*showErrorChain {
+		return nil
+	}
+	return s.errorChains[msg]
+}
+
+// ErrorExample is an original error message that was normalized to msg by
+// SetErrorNormalizer, or "" if msg wasn't normalized.
+func (s summary) ErrorExample(msg string) string {
+	return s.errorExamples[msg]
+}
+
+// DuplicateRows are the grievances registered more than once, by dedup key.
+func (s summary) DuplicateRows() []reportRow { return s.duplicateRows }
+
+// FileRows are the source file counts, most disappointing first.
+func (s summary) FileRows() []reportRow { return s.fileRows }
+
+type overdueRow struct {
+	Test      string
+	Grievance string
+	Deadline  time.Time
+}
+
+// OverdueRows are the grievances past their deadline, most overdue first.
+func (s summary) OverdueRows() []overdueRow { return s.overdueRows }
+
+// FuzzRows are the counts by fuzz target for grievances registered by fuzz
+// inputs, most disappointing first.
+func (s summary) FuzzRows() []reportRow { return s.fuzzRows }
+
+// PackageRows are the counts by the package of the code under test that filed
+// each grievance, most disappointing first.
+func (s summary) PackageRows() []reportRow { return s.packageRows }
+
+// DroppedRows are the tests that went over the per-test limit, with how many
+// of their grievances were dropped.
+func (s summary) DroppedRows() []reportRow { return s.droppedRows }
+
+// FailedTests are the tests that failed outright on top of their grievances,
+// in name order.
+func (s summary) FailedTests() []string {
+	var names []string
+	for name, failed := range s.Failed {
+		if failed {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Escalations are the tags whose severity was escalated for showing up too
+// often.
+func (s summary) Escalations() []escalationRow { return s.escalationRows }
+
+// Dimensions are the counts for each regex dimension, in the order they were
+// added.
+func (s summary) Dimensions() []dimensionRows { return s.dimensionRows }
+
+// testLineage returns a test name and the names of all its parents, so
+// "TestA/b/c" gives TestA/b/c, TestA/b and TestA.
+func testLineage(name string) []string {
+	lineage := []string{name}
+	for i := strings.LastIndex(name, "/"); i > 0; i = strings.LastIndex(name, "/") {
+		name = name[:i]
+		lineage = append(lineage, name)
+	}
+	return lineage
 }
 
 func (d *disappointments) summarize() summary {
-	s := summary{}
+	d.recheck()
+	s := summary{Commit: d.Commit, Seed: d.Seed, Dropped: d.dropped, Skipped: d.skipped, Suppressed: d.suppressed, Failed: d.Failed, timeline: d.timeline()}
+	for name, c := range d.dropped {
+		s.droppedRows = append(s.droppedRows, reportRow{ID: name, Count: c})
+	}
+	sortRows(s.droppedRows)
+	if *foldCase {
+		s.tagNames = foldedTagNames(d)
+	}
 	count := 0
 
 	// count grievances by tag
@@ -119,22 +511,37 @@ func (d *disappointments) summarize() summary {
 	for _, v := range d.Grievances {
 		count += len(v)
 		for _, g := range v {
-			for _, t := range g.Tags {
+			for _, t := range s.tags(g) {
 				countByTag[t] = countByTag[t] + 1
 			}
+			if len(g.Tags) == 0 && *untaggedBucket != "" {
+				countByTag[*untaggedBucket]++
+			}
 		}
 	}
 	s.ByTag = countByTag
+	s.TagLinks = make(map[string]string)
 	for t, c := range countByTag {
-		s.tagRows = append(s.tagRows, reportRow{ID: t, Count: c})
+		link := s.tagLink(t, c)
+		if link != "" {
+			s.TagLinks[t] = link
+		}
+		s.tagRows = append(s.tagRows, reportRow{ID: t, Count: c, Link: link})
 	}
 
-	sort.SliceStable(s.tagRows, func(i, j int) bool {
-		return s.tagRows[i].Count > s.tagRows[j].Count
-	})
+	sortRows(s.tagRows)
 
 	s.Total = count
 
+	// count the tests that ran, with or without grievances
+	tests := len(d.tests)
+	for name := range d.Grievances {
+		if !d.tests[name] {
+			tests++
+		}
+	}
+	s.Tests = tests
+
 	// count grievances by name
 	countByName := make(map[string]int)
 	for _, v := range d.Grievances {
@@ -148,16 +555,103 @@ func (d *disappointments) summarize() summary {
 		s.nameRows = append(s.nameRows, reportRow{ID: t, Count: c})
 	}
 
-	sort.SliceStable(s.nameRows, func(i, j int) bool {
-		return s.nameRows[i].Count > s.nameRows[j].Count
-	})
+	sortRows(s.nameRows)
+
+	// count grievances by the source file they were registered from
+	countByFile := make(map[string]int)
+	for _, v := range d.Grievances {
+		for _, g := range v {
+			if g.File != "" {
+				countByFile[g.File]++
+			}
+		}
+	}
+	s.ByFile = countByFile
+	for f, c := range countByFile {
+		s.fileRows = append(s.fileRows, reportRow{ID: f, Count: c})
+	}
+	sortRows(s.fileRows)
+
+	// count the grievances of fuzz inputs by fuzz target
+	s.ByFuzz = make(map[string]int)
+	inputs := make(map[string]int)
+	for name, v := range d.Grievances {
+		if target := fuzzTarget(name); target != "" {
+			s.ByFuzz[target] += len(v)
+			inputs[target]++
+		}
+	}
+	for target, c := range s.ByFuzz {
+		id := fmt.Sprintf("%s (%d inputs)", target, inputs[target])
+		if inputs[target] == 1 {
+			id = fmt.Sprintf("%s (1 input)", target)
+		}
+		s.fuzzRows = append(s.fuzzRows, reportRow{ID: id, Count: c})
+	}
+	sortRows(s.fuzzRows)
+
+	// count grievances by the package of the code under test that filed them
+	s.ByPackage = make(map[string]int)
+	for _, v := range d.Grievances {
+		for _, g := range v {
+			if g.Package != "" {
+				s.ByPackage[g.Package]++
+			}
+		}
+	}
+	for p, c := range s.ByPackage {
+		s.packageRows = append(s.packageRows, reportRow{ID: p, Count: c})
+	}
+	sortRows(s.packageRows)
+
+	// roll subtest tags up into every ancestor test
+	s.TagsByTest = make(map[string]map[string]int)
+	for name, v := range d.Grievances {
+		for _, g := range v {
+			for _, t := range s.tags(g) {
+				for _, n := range testLineage(name) {
+					pairCount(s.TagsByTest, n, t)
+				}
+			}
+		}
+	}
+
+	// count grievances that collapse to the same dedup key
+	countByKey := make(map[string]int)
+	representative := make(map[string]string)
+	for _, v := range d.Grievances {
+		for _, g := range v {
+			k := dedupKey(g)
+			countByKey[k]++
+			if _, ok := representative[k]; !ok {
+				representative[k] = g.String()
+			}
+		}
+	}
+	s.Duplicates = make(map[string]int)
+	for k, c := range countByKey {
+		if c > 1 {
+			s.Duplicates[k] = c
+			s.duplicateRows = append(s.duplicateRows, reportRow{ID: representative[k], Count: c})
+		}
+	}
+	sortRows(s.duplicateRows)
 
 	// count grievances by error
 	countByError := make(map[string]int)
+	s.errorChains = make(map[string][]string)
+	s.errorExamples = make(map[string]string)
 	for _, v := range d.Grievances {
 		for _, g := range v {
 			if g.Error != nil {
-				countByError[g.Error.Error()] = countByError[g.Error.Error()] + 1
+				msg := normalizeError(g.Error.Error())
+				countByError[msg] = countByError[msg] + 1
+				if _, ok := s.errorChains[msg]; !ok {
+					s.errorChains[msg] = errorChain(g.Error)
+				}
+				if ex, ok := s.errorExamples[msg]; msg != g.Error.Error() && (!ok || g.Error.Error() < ex) {
+					s.errorExamples[msg] = g.Error.Error()
+				}
 			}
 		}
 	}
@@ -166,9 +660,131 @@ func (d *disappointments) summarize() summary {
 		s.errorRows = append(s.errorRows, reportRow{ID: e, Count: c})
 	}
 
-	sort.SliceStable(s.errorRows, func(i, j int) bool {
-		return s.errorRows[i].Count > s.errorRows[j].Count
+	sortRows(s.errorRows)
+
+	// escalate tags that have been disappointing too often
+	s.Escalated = make(map[string]Severity)
+	for key, e := range escalations {
+		tag := s.tagName(key)
+		if c := countByTag[tag]; c > e.count && e.to > s.Escalated[tag] {
+			s.Escalated[tag] = e.to
+			s.escalationRows = append(s.escalationRows, escalationRow{Tag: tag, Count: c, Limit: e.count, Severity: e.to})
+		}
+	}
+	sort.Slice(s.escalationRows, func(i, j int) bool {
+		return s.escalationRows[i].Tag < s.escalationRows[j].Tag
+	})
+
+	// collect latency percentiles for tags whose grievances have durations
+	durationsByTag := make(map[string][]time.Duration)
+	for _, v := range d.Grievances {
+		for _, g := range v {
+			if g.Duration <= 0 {
+				continue
+			}
+			for _, t := range s.tags(g) {
+				durationsByTag[t] = append(durationsByTag[t], g.Duration)
+			}
+		}
+	}
+	s.Latency = make(map[string]Latency)
+	for t, durs := range durationsByTag {
+		s.Latency[t] = newLatency(durs)
+	}
+
+	// list the grievances that are past their deadline
+	for _, v := range d.Grievances {
+		for _, g := range v {
+			if g.overdue() {
+				s.overdueRows = append(s.overdueRows, overdueRow{Test: g.Name, Grievance: g.String(), Deadline: g.Deadline})
+			}
+		}
+	}
+	sort.Slice(s.overdueRows, func(i, j int) bool {
+		a, b := s.overdueRows[i], s.overdueRows[j]
+		if !a.Deadline.Equal(b.Deadline) {
+			return a.Deadline.Before(b.Deadline)
+		}
+		return a.Test < b.Test
 	})
+	s.Overdue = len(s.overdueRows)
+
+	// count grievances by severity
+	countBySeverity := make(map[string]int)
+	for _, v := range d.Grievances {
+		for _, g := range v {
+			countBySeverity[s.severityOf(g).String()]++
+		}
+	}
+	s.BySeverity = countBySeverity
+
+	// score grievances by severity weight
+	s.ScoreByTag = make(map[string]int)
+	s.ScoreByName = make(map[string]int)
+	for _, v := range d.Grievances {
+		for _, g := range v {
+			w := weighScore(g.Name, severityWeights[s.severityOf(g)])
+			s.Score += w
+			s.ScoreByName[g.Name] += w
+			for _, t := range s.tags(g) {
+				s.ScoreByTag[t] += w
+			}
+		}
+	}
+	for i, r := range s.tagRows {
+		s.tagRows[i].Score, s.tagRows[i].scored = s.ScoreByTag[r.ID], true
+	}
+	for i, r := range s.nameRows {
+		s.nameRows[i].Score, s.nameRows[i].scored = s.ScoreByName[r.ID], true
+	}
+	if len(testWeights) > 0 {
+		sortRowsByScore(s.nameRows)
+	}
+
+	// count the pairs of tags that show up on the same grievance
+	s.TagPairs = make(map[string]map[string]int)
+	countByPair := make(map[string]int)
+	for _, v := range d.Grievances {
+		for _, g := range v {
+			tags := s.tags(g)
+			for i, a := range tags {
+				for _, b := range tags[i+1:] {
+					first, second := a, b
+					if second < first {
+						first, second = second, first
+					}
+					countByPair[first+" + "+second]++
+					pairCount(s.TagPairs, a, b)
+					pairCount(s.TagPairs, b, a)
+				}
+			}
+		}
+	}
+	for p, c := range countByPair {
+		s.pairRows = append(s.pairRows, reportRow{ID: p, Count: c})
+	}
+	sortRows(s.pairRows)
+
+	// count grievances by the dimensions extracted from their messages
+	s.ByDimension = make(map[string]map[string]int)
+	for _, dim := range dimensions {
+		counts := make(map[string]int)
+		for _, v := range d.Grievances {
+			for _, g := range v {
+				if m := dim.re.FindStringSubmatch(g.Message); len(m) > 1 {
+					counts[m[1]]++
+				}
+			}
+		}
+		s.ByDimension[dim.name] = counts
+
+		dr := dimensionRows{Name: dim.name}
+		for k, c := range counts {
+			dr.Rows = append(dr.Rows, reportRow{ID: k, Count: c})
+		}
+		sortRows(dr.Rows)
+		s.dimensionRows = append(s.dimensionRows, dr)
+	}
 
 	return s
 }
@@ -179,13 +795,106 @@ type Disappointment interface {
 	WithMessage(msg string) Disappointment
 	WithError(err error) Disappointment
 	WithTags(tags ...string) Disappointment
+	WithArtifact(path string) Disappointment
+	WithSeverity(s Severity) Disappointment
+	WithDuration(dur time.Duration) Disappointment
+	WithField(key string, value interface{}) Disappointment
+	WithDeadline(deadline time.Time) Disappointment
+	Key() string
+	ID() string
 }
 
 type disappointment struct {
-	Message string   `json:"message"`
-	Tags    []string `json:"tags"`
-	Error   error    `json:"error"`
-	Name    string   `json:"testName"`
+	UID      string        `json:"id,omitempty"`
+	Message  string        `json:"message"`
+	Tags     []string      `json:"tags"`
+	Error    error         `json:"error"`
+	Name     string        `json:"testName"`
+	Path     []string      `json:"subtestPath,omitempty"`
+	Artifact string        `json:"artifact,omitempty"`
+	Severity Severity      `json:"severity"`
+	Duration time.Duration `json:"duration,omitempty"`
+	Deadline time.Time     `json:"deadline,omitzero"`
+	Time     time.Time     `json:"time,omitzero"`
+	File     string        `json:"file,omitempty"`
+	Line     int           `json:"line,omitempty"`
+	Package  string        `json:"package,omitempty"`
+
+	Fields map[string]interface{} `json:"fields,omitempty"`
+
+	Occurrences int `json:"occurrences,omitempty"`
+
+	suppressed bool
+	// owner is the disappointments g was added to, which rechecks it when
+	// its severity changes
+	owner *disappointments
+	// t is the test that registered the grievance, if any, so that
+	// -testivus.abortoncritical can stop it
+	t testing.TB
+	// held is set while hooks and the classifier run on g, when the lock may
+	// be held and stopping the test has to wait for the caller to release it
+	held bool
+	// onError is told about errors attached later, such as by GrievanceSpan
+	onError func(error)
+}
+
+// MarshalJSON renders the disappointment to JSON, with its error as a string
+func (d disappointment) MarshalJSON() ([]byte, error) {
+	type plain disappointment
+	v := struct {
+		*plain
+		Error      *string      `json:"error"`
+		ErrorChain []string     `json:"errorChain,omitempty"`
+		Duration   jsonDuration `json:"duration,omitempty"`
+	}{plain: (*plain)(&d), Duration: jsonDuration(d.Duration)}
+
+	if d.Error != nil {
+		e := d.Error.Error()
+		v.Error = &e
+		v.ErrorChain = errorChain(d.Error)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil || len(jsonFieldNames) == 0 {
+		return b, err
+	}
+	return renameFields(b, jsonFieldNames)
+}
+
+// UnmarshalJSON reads a disappointment from JSON
+func (d *disappointment) UnmarshalJSON(b []byte) error {
+	type plain disappointment
+	v := struct {
+		*plain
+		Error      *string      `json:"error"`
+		ErrorChain []string     `json:"errorChain"`
+		Duration   jsonDuration `json:"duration"`
+	}{plain: (*plain)(d)}
+
+	if len(jsonFieldNames) > 0 {
+		var err error
+		if b, err = renameFields(b, jsonFieldOriginals()); err != nil {
+			return err
+		}
+	}
+
+	if err := unmarshal(b, &v); err != nil {
+		return err
+	}
+	if v.Error != nil {
+		d.Error = errors.New(*v.Error)
+	}
+	d.Duration = time.Duration(v.Duration)
+	return nil
+}
+
+// errorChain lists the messages of the errors wrapped by err, outermost first.
+func errorChain(err error) []string {
+	var chain []string
+	for e := stderrors.Unwrap(err); e != nil; e = stderrors.Unwrap(e) {
+		chain = append(chain, e.Error())
+	}
+	return chain
 }
 
 func (d disappointment) String() string {
@@ -210,6 +919,10 @@ func (d *disappointment) WithMessage(msg string) Disappointment {
 // WithError adds an error to the disappointment
 func (d *disappointment) WithError(err error) Disappointment {
 	d.Error = err
+	if d.onError != nil {
+		d.onError(err)
+	}
+	abortIfCritical(d)
 	return d
 }
 
@@ -219,56 +932,533 @@ func (d *disappointment) WithTags(tags ...string) Disappointment {
 	return d
 }
 
-var running *disappointments
+// WithArtifact links a supporting file, like a diff or a heap profile, to the
+// disappointment. A warning is printed if the file does not exist.
+func (d *disappointment) WithArtifact(path string) Disappointment {
+	if _, err := os.Stat(path); err != nil {
+		fmt.Printf("WARNING: artifact for %q not found: %s\n", d.Message, path)
+	}
+	d.Artifact = path
+	return d
+}
+
+// WithSeverity sets how badly the disappointment let you down
+func (d *disappointment) WithSeverity(s Severity) Disappointment {
+	d.Severity = s
+	if minSeverity > 0 && d.owner != nil {
+		d.owner.rechecksMu.Lock()
+		d.owner.rechecks = append(d.owner.rechecks, d)
+		d.owner.rechecksMu.Unlock()
+	}
+	abortIfCritical(d)
+	return d
+}
+
+// WithDuration records how long the disappointing operation took. If latency
+// buckets are set the grievance is also tagged with the slowest bucket it
+// exceeded.
+func (d *disappointment) WithDuration(dur time.Duration) Disappointment {
+	d.Duration = dur
+
+	tags := d.Tags[:0:0]
+	for _, t := range d.Tags {
+		if !strings.HasPrefix(t, latencyTagPrefix) {
+			tags = append(tags, t)
+		}
+	}
+	if t := latencyTag(dur); t != "" {
+		tags = append(tags, t)
+	}
+	d.Tags = tags
+	return d
+}
+
+// WithField attaches a named value, like a measurement, to the disappointment
+func (d *disappointment) WithField(key string, value interface{}) Disappointment {
+	if d.Fields == nil {
+		d.Fields = make(map[string]interface{})
+	}
+	d.Fields[key] = value
+	return d
+}
+
+// WithDeadline sets the date the disappointment should be fixed by. Once it
+// has passed the grievance is Critical and listed as overdue.
+func (d *disappointment) WithDeadline(deadline time.Time) Disappointment {
+	d.Deadline = deadline
+	abortIfCritical(d)
+	return d
+}
+
+// overdue reports whether the disappointment's deadline has passed.
+func (d *disappointment) overdue() bool {
+	return !d.Deadline.IsZero() && now().After(d.Deadline)
+}
+
+// ID is the unique identifier the disappointment was given when it was
+// registered. It is saved with the report, so the same grievance can be
+// referred to in other reports and systems. Grievances read from reports
+// written before IDs existed have none.
+func (d *disappointment) ID() string {
+	return d.UID
+}
+
+// Key identifies the disappointment by its test name, message, tags and error,
+// so equal grievances have equal keys. Tag order does not matter.
+func (d *disappointment) Key() string {
+	tags := append([]string(nil), d.Tags...)
+	sort.Strings(tags)
+
+	var e string
+	if d.Error != nil {
+		e = d.Error.Error()
+	}
+
+	h := sha256.New()
+	for _, part := range []string{d.Name, d.Message, strings.Join(tags, "\x00"), e} {
+		io.WriteString(h, part)
+		h.Write([]byte{0xff})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// severity is the severity of the disappointment, Minor unless it was set.
+func (d *disappointment) severity() Severity {
+	if d.Severity == 0 {
+		return Minor
+	}
+	return d.Severity
+}
+
+// running collects the grievances of the run. It exists before Run so
+// grievances can be registered while TestMain sets up.
+var running = func() *disappointments {
+	d := newDisappointments(nil)
+	d.Start = now()
+	return d
+}()
 
 // Run can be used in place of TestMain to allow disappointment reporting
 func Run(m *testing.M) int {
+	return RunWithConfig(m, Config{})
+}
+
+func run(m *testing.M) int {
 	flag.Parse()
-	running = newDisappointments(m)
+
+	if _, ok := formats[*format]; !ok {
+		fmt.Printf("invalid -testivus.format: unknown format %q\n", *format)
+		return 1
+	}
+
+	if *timelineFormat != "" && *timelineFormat != "relative" && *timelineFormat != "absolute" {
+		fmt.Printf("invalid -testivus.timeline: %q is not relative or absolute\n", *timelineFormat)
+		return 1
+	}
+
+	if *barMetric != "count" && *barMetric != "score" {
+		fmt.Printf("invalid -testivus.barmetric: %q is not count or score\n", *barMetric)
+		return 1
+	}
+
+	if *liveFormat != "default" && *liveFormat != "prefix" {
+		fmt.Printf("invalid -testivus.liveformat: %q is not default or prefix\n", *liveFormat)
+		return 1
+	}
+
+	if *padding < 0 || *minWidth < 0 {
+		fmt.Printf("invalid -testivus.padding or -testivus.minwidth: %d and %d must not be negative\n", *padding, *minWidth)
+		return 1
+	}
+
+	if err := localCheckpoint(*checkpointFile); err != nil {
+		fmt.Println(errors.Wrap(err, "invalid -testivus.checkpoint"))
+		return 1
+	}
+
+	var err error
+	if *downgrade != "" {
+		if downgradePattern, err = regexp.Compile(*downgrade); err != nil {
+			fmt.Println(errors.Wrap(err, "invalid -testivus.downgrade"))
+			return 1
+		}
+	}
+
+	outputs, err = parseOutputs(*outputSpec)
+	if err != nil {
+		fmt.Println(errors.Wrap(err, "invalid -testivus.outputs"))
+		return 1
+	}
+	for format, path := range fileFlags {
+		if *path != "" {
+			outputs = append(outputs, output{format: format, path: *path})
+		}
+	}
+
+	if store != nil {
+		running.store = store
+	}
+	if *seedFlag != 0 {
+		SetSeed(*seedFlag)
+	}
+	running.Seed = seed
+	running.Commit = currentCommit()
+	if *checkpointFile != "" {
+		if err := Load(*checkpointFile); err != nil && !os.IsNotExist(errors.Cause(err)) {
+			fmt.Println(errors.Wrap(err, "could not resume from checkpoint"))
+			return 1
+		}
+	}
+
+	if *eventsFile != "" {
+		if err := openEvents(*eventsFile); err != nil {
+			fmt.Println(errors.Wrap(err, "could not open -testivus.events"))
+			return 1
+		}
+		defer closeEvents()
+	}
+	emit("start", map[string]interface{}{"commit": running.Commit})
+
 	code := m.Run()
-	err := report(running)
+	for _, fn := range teardowns {
+		fn()
+	}
+	running.collect()
+	err = report(running, code)
+	emit("end", map[string]interface{}{"exitCode": code, "summary": running.Summary})
 	if err != nil {
 		fmt.Println(errors.Wrap(err, "could not save report"))
 		return 1
 	}
-	return code
+
+	if *checkpointFile != "" {
+		os.Remove(*checkpointFile)
+	}
+	r := newReport(running, code)
+	if over := overBudget(r); over != "" {
+		fmt.Print(over)
+	}
+
+	return exitCode(r)
+}
+
+// exitCode is the exit code the exit policy picks for r, or the tests' own
+// with -testivus.cionly outside of CI.
+func exitCode(r *Report) int {
+	if *ciOnly && !inCI() {
+		return r.ExitCode()
+	}
+	return exitPolicy(r)
 }
 
 // New creates a new set of disappointments.
 // Use this only if you need a custom TestMain. Otherwise you should just use Run.
 func newDisappointments(m *testing.M) *disappointments {
-	return &disappointments{Grievances: make(map[string][]*disappointment)}
+	d := &disappointments{
+		Grievances: make(map[string][]*disappointment),
+		once:       make(map[string]*disappointment),
+		tests:      make(map[string]bool),
+		dropped:    make(map[string]int),
+		stored:     make(map[string]int),
+		sections:   make(map[string][]*section),
+		parallel:   make(map[string]bool),
+		Failed:     make(map[string]bool),
+	}
+	d.store = memoryStore{d}
+	return d
 }
 
 // Report airs your grievances and shows a report of your disappointments.
 // Use this only if you need a custom TestMain. Otherwise you should just use Run.
-func report(d *disappointments) error {
-	fmt.Printf(d.String())
+func report(d *disappointments, code int) error {
+	switch {
+	case *golden:
+		fmt.Print(d.golden())
+	case *format != "text":
+		fmt.Print(formats[*format](d))
+	default:
+		fmt.Print(d.String())
+		if footer != nil {
+			fmt.Print(footer(newReport(d, code)))
+		}
+	}
+
+	d.Summary = d.summarize()
+	if w := tagSprawl(d.Summary, *maxTags); w != "" {
+		fmt.Println(w)
+	}
+
+	// a writer that fails doesn't stop the others; it is aired as a grievance
+	// in the outputs written after it and returned once they are all done
+	var failed error
+	fail := func(err error) {
+		if failed == nil {
+			failed = err
+		}
+		d.Lock()
+		d.writeFailed(err)
+		d.Unlock()
+		d.Summary = d.summarize()
+	}
 
 	if *reportFile != "" {
-		// save output to file
-		out, err := os.OpenFile(*reportFile, os.O_CREATE|os.O_WRONLY, 0600)
-		if err != nil {
-			return err
+		if err := writeSampledJSON(*reportFile, d); err != nil {
+			fail(err)
 		}
-		defer out.Close()
+	}
 
-		err = json.NewEncoder(out).Encode(d)
-		if err != nil {
-			return err
+	for _, o := range outputs {
+		if err := outputWriters[o.format](o.path, d); err != nil {
+			fail(errors.Wrapf(err, "%s output", o.format))
 		}
-		out.Sync()
 	}
 
-	return nil
+	// files go by the effective severity, so downgraded grievances stay out
+	// of the gates reading them
+	for sev, paths := range severityOut {
+		f := d.filter(func(g *disappointment) bool { return d.Summary.severityOf(g) == sev })
+		for _, p := range paths {
+			if err := writeJSON(p, f); err != nil {
+				fail(err)
+			}
+		}
+	}
+
+	if *githubSummary {
+		if err := d.githubSummary(); err != nil {
+			fail(errors.Wrap(err, "github summary"))
+		}
+	}
+
+	return failed
+}
+
+// tagSprawl warns when there are more than max distinct tags, listing the least
+// used ones since they are the likeliest typos.
+func tagSprawl(s summary, max int) string {
+	if max <= 0 || len(s.tagRows) <= max {
+		return ""
+	}
+
+	var rare []string
+	for _, r := range s.tagRows[max:] {
+		rare = append(rare, fmt.Sprintf("%s (%d)", r.ID, r.Count))
+	}
+	return fmt.Sprintf("WARNING: %d distinct tags is more than %d, check these for typos: %s",
+		len(s.tagRows), max, strings.Join(rare, ", "))
+}
+
+// writeJSON saves the disappointments to path as JSON.
+func writeJSON(path string, d *disappointments) error {
+	return writeFile(path, func(w io.Writer) error {
+		return json.NewEncoder(w).Encode(d)
+	})
+}
+
+// filter returns a summarized copy holding only the grievances that match.
+func (d *disappointments) filter(match func(*disappointment) bool) *disappointments {
+	f := newDisappointments(nil)
+	for name, v := range d.Grievances {
+		for _, g := range v {
+			if match(g) {
+				f.Grievances[name] = append(f.Grievances[name], g)
+			}
+		}
+	}
+	f.Summary = f.summarize()
+	return f
 }
 
-// Grievance registers a disappointment with your code.
-func Grievance(t *testing.T, msg string, tags ...string) Disappointment {
+// Grievance registers a disappointment with your code. Like the rest of the
+// API it takes a testing.TB, so it works in tests, benchmarks and fuzz
+// targets alike.
+func Grievance(t testing.TB, msg string, tags ...string) Disappointment {
 	t.Helper()
+	running.Lock()
+	g := running.record(t, t.Name(), msg, tags)
+	running.Unlock()
+
+	registered(t, g)
+	return g
+}
+
+// registered stops t after it registered g, if -testivus.abortoncritical or
+// SetFailFastPerTest say to. Every way of registering a grievance for a test
+// calls it once the lock is released, since it may not return.
+func registered(t testing.TB, g *disappointment) {
+	t.Helper()
+	abortIfCritical(g)
+	failFast(t)
+}
+
+// failFast stops t after a grievance if SetFailFastPerTest is on. It must be
+// called without the lock, since it doesn't return.
+func failFast(t testing.TB) {
+	if failFastPerTest {
+		if failFastSkip {
+			t.SkipNow()
+		}
+		t.FailNow()
+	}
+}
+
+var failFastPerTest, failFastSkip bool
+
+// SetFailFastPerTest stops a test at its first grievance, for tests where one
+// disappointment makes the rest of the work pointless. The test is failed,
+// or skipped if SetFailFastSkip is on.
+func SetFailFastPerTest(enabled bool) {
+	failFastPerTest = enabled
+}
+
+// SetFailFastSkip makes SetFailFastPerTest skip the rest of the test instead
+// of failing it.
+func SetFailFastSkip(skip bool) {
+	failFastSkip = skip
+}
+
+// GlobalName is the test name given to grievances registered with
+// GrievanceGlobal.
+const GlobalName = "<global>"
+
+// GrievanceGlobal registers a disappointment that doesn't belong to any test,
+// such as one from a background daemon. It is safe to call from any
+// goroutine, and is grouped under GlobalName in the report.
+func GrievanceGlobal(msg string, tags ...string) Disappointment {
+	return GrievanceNamed(GlobalName, msg, tags...)
+}
+
+// MainName is the test name given to grievances registered with
+// GrievanceMain.
+const MainName = "TestMain"
+
+// GrievanceMain registers a disappointment about shared fixtures, from
+// TestMain before Run or from a teardown registered with OnTeardown. It is
+// grouped under MainName in the report.
+func GrievanceMain(msg string, tags ...string) Disappointment {
+	return GrievanceNamed(MainName, msg, tags...)
+}
+
+var teardowns []func()
+
+// OnTeardown registers fn to run after the tests finish but before the
+// report, so teardown in TestMain can still register grievances with
+// GrievanceMain.
+func OnTeardown(fn func()) {
+	teardowns = append(teardowns, fn)
+}
+
+// GrievanceNamed registers a disappointment against an explicit test name,
+// for frameworks like Ginkgo where t.Name() doesn't say much.
+func GrievanceNamed(name, msg string, tags ...string) Disappointment {
 	running.Lock()
 	defer running.Unlock()
 
+	return running.record(nil, name, msg, tags)
+}
+
+// GrievanceOnce registers a disappointment only the first time key is seen
+// during the run. Later calls with the same key are counted as occurrences of
+// the original grievance instead of being registered again.
+func GrievanceOnce(key string, t testing.TB, msg string, tags ...string) Disappointment {
+	t.Helper()
+	running.Lock()
+	g, seen := running.once[key]
+	if seen {
+		g.Occurrences++
+	} else {
+		g = running.record(t, t.Name(), msg, tags)
+		g.Occurrences = 1
+		running.once[key] = g
+	}
+	running.Unlock()
+
+	if !seen {
+		registered(t, g)
+	}
+	return g
+}
+
+// record stores a new grievance under name. The caller must hold the lock.
+func (d *disappointments) record(t testing.TB, name, msg string, tags []string) *disappointment {
+	if t != nil {
+		t.Helper()
+	}
+
+	// grievances registered while TestMain sets up come before Run parses
+	// the flags they depend on
+	if !flag.Parsed() {
+		flag.Parse()
+	}
+
+	g := newGrievance(name, msg, tags)
+	g.t = t
+	classify(g)
+	d.add(t, g)
+	return g
+}
+
+// add stores a grievance under its test name. The caller must hold the lock.
+func (d *disappointments) add(t testing.TB, g *disappointment) {
+	if t != nil {
+		t.Helper()
+	}
+
+	g.owner = d
+	if belowMinSeverity(g) {
+		g.suppressed = true
+		d.suppressed++
+		return
+	}
+
+	if perTestLimit > 0 && d.stored[g.Name] >= perTestLimit {
+		d.dropped[g.Name]++
+		return
+	}
+
+	if t != nil {
+		d.watch(t)
+	}
+	d.enterSections(g)
+	d.tagParallel(g)
+	announce(t, g)
+	d.store.Add(g)
+	d.stored[g.Name]++
+	d.remember(g)
+	// the event has the grievance as registered, before any chained With calls
+	emit("grievance", map[string]interface{}{"grievance": g})
+
+	g.held = true
+	for _, h := range hooks {
+		h(g)
+	}
+	g.held = false
+}
+
+var perTestLimit int
+
+// SetPerTestLimit caps the grievances stored for each test at n. Grievances
+// over the cap are dropped, and the report shows how many each test lost. A
+// limit of zero, the default, stores everything.
+func SetPerTestLimit(n int) {
+	perTestLimit = n
+}
+
+var hooks []func(Disappointment)
+
+// OnGrievance registers a hook called every time a grievance is stored. Hooks
+// run in the order they were registered. They are called while the lock is
+// held, so they must not block or register grievances themselves.
+func OnGrievance(hook func(Disappointment)) {
+	hooks = append(hooks, hook)
+}
+
+// newGrievance builds a grievance with its tags deduplicated.
+func newGrievance(name, msg string, tags []string) *disappointment {
+	if *platformTags {
+		tags = append(tags[:len(tags):len(tags)], "os:"+runtime.GOOS, "arch:"+runtime.GOARCH)
+	}
+
 	var uniq []string
 	used := make(map[string]string)
 	for _, t := range tags {
@@ -279,24 +1469,130 @@ func Grievance(t *testing.T, msg string, tags ...string) Disappointment {
 		uniq = append(uniq, t)
 	}
 
-	g := &disappointment{Name: t.Name(), Message: msg, Tags: uniq}
-	if testing.Verbose() {
-		fmt.Println("GRIEVANCE:", g)
+	g := &disappointment{UID: newID(), Time: now(), Name: name, Path: strings.Split(name, "/"), Message: msg, Tags: uniq}
+	if *captureSource {
+		if stack := callerFrames(); len(stack) > 0 {
+			g.File, g.Line = sourceFile(stack[0].File), stack[0].Line
+			g.Package = sourcePackage(stack)
+		}
+	}
+	return g
+}
+
+// announce prints a grievance as it is registered. With -testivus.testlog it
+// goes through t.Log so it stays with its test, otherwise it is printed in
+// verbose mode. The prefix live format shows the severity the grievance has
+// when it is registered, before any WithSeverity.
+func announce(t testing.TB, g *disappointment) {
+	line := fmt.Sprint("GRIEVANCE: ", g)
+	if *liveFormat == "prefix" {
+		line = fmt.Sprintf("[%s][%s] %s", g.Name, inferSeverity(g), g)
+	}
+
+	if *testLog && t != nil {
+		t.Helper()
+		t.Log(line)
+		return
 	}
 
-	v, ok := running.Grievances[t.Name()]
+	if verbose() {
+		fmt.Println(line)
+	}
+}
+
+// Retract removes a grievance registered earlier, for when it turns out to be
+// spurious. It reports whether the grievance was found.
+func Retract(d Disappointment) bool {
+	g, ok := d.(*disappointment)
 	if !ok {
-		running.Grievances[t.Name()] = []*disappointment{g}
-		return g
+		return false
 	}
 
-	v = append(v, g)
-	running.Grievances[t.Name()] = v
-	return g
+	running.Lock()
+	defer running.Unlock()
+	return running.remove(g)
+}
+
+// remove takes g out of the stored grievances, reporting whether it was there.
+// The caller must hold the lock.
+func (d *disappointments) remove(g *disappointment) bool {
+	v := d.Grievances[g.Name]
+	for i := range v {
+		if v[i] != g {
+			continue
+		}
+
+		if len(v) == 1 {
+			delete(d.Grievances, g.Name)
+		} else {
+			d.Grievances[g.Name] = append(v[:i:i], v[i+1:]...)
+		}
+		if d.stored[g.Name]--; d.stored[g.Name] <= 0 {
+			delete(d.stored, g.Name)
+		}
+		d.forget(g)
+		for k, o := range d.once {
+			if o == g {
+				delete(d.once, k)
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Sequence returns the grievances registered by t so far, in the order they
+// were registered.
+func Sequence(t testing.TB) []Disappointment {
+	running.Lock()
+	defer running.Unlock()
+
+	running.recheck()
+	v := running.Grievances[t.Name()]
+	seq := make([]Disappointment, 0, len(v))
+	for _, g := range v {
+		seq = append(seq, g)
+	}
+	return seq
+}
+
+// Track counts t among the tests that ran, so the report can tell you how many
+// tests let you down. Tests that register grievances are counted already.
+func Track(t testing.TB) {
+	running.Lock()
+	defer running.Unlock()
+
+	running.tests[t.Name()] = true
+	running.watch(t)
+}
+
+// watch records whether t passed or failed once it finishes. The caller must
+// hold the lock.
+func (d *disappointments) watch(t testing.TB) {
+	name := t.Name()
+	if _, ok := d.Failed[name]; ok {
+		return
+	}
+
+	d.Failed[name] = false
+	t.Cleanup(func() {
+		d.Lock()
+		defer d.Unlock()
+		d.Failed[name] = t.Failed()
+	})
 }
 
 // Failure registers a disappointment and fails the test.
-func Failure(t *testing.T, msg string, tags ...string) Disappointment {
+func Failure(t testing.TB, msg string, tags ...string) Disappointment {
+	t.Helper()
+
+	// fail while holding the lock, so nothing sees the grievance without the
+	// failure or the failure without the grievance
+	running.Lock()
+	g := running.record(t, t.Name(), msg, tags)
 	t.Fail()
-	return Grievance(t, msg, tags...)
+	running.Unlock()
+
+	registered(t, g)
+	return g
 }
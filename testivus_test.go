@@ -2,13 +2,23 @@ package testivus_test
 
 import (
 	"errors"
+	"fmt"
+	"io/fs"
 	"os"
+	"strings"
+	"sync"
 	"testing"
+	"testing/fstest"
 
 	"github.com/britt/testivus"
 )
 
 func TestMain(m *testing.M) {
+	testivus.GrievanceMain("The pole took too long to put up.", "setup")
+	testivus.OnTeardown(func() {
+		testivus.GrievanceMain("Nobody helped take the pole down.", "teardown")
+	})
+
 	code := testivus.Run(m)
 	os.Exit(code)
 }
@@ -18,3 +28,271 @@ func TestTestivus(t *testing.T) {
 	testivus.Grievance(t, "You're slow!", "speed").WithError(errors.New("timeout exceeded"))
 	testivus.Grievance(t, "You're send too much data!", "speed", "download")
 }
+
+func TestKey(t *testing.T) {
+	a := testivus.Grievance(t, "You're slow!", "speed", "download")
+	b := testivus.Grievance(t, "You're slow!", "download", "speed")
+	c := testivus.Grievance(t, "You're slow!", "speed")
+
+	if a.Key() != b.Key() {
+		t.Errorf("keys differ for the same grievance: %s != %s", a.Key(), b.Key())
+	}
+	if a.Key() == c.Key() {
+		t.Errorf("keys match for different grievances: %s", a.Key())
+	}
+}
+
+// since lists the grievances t registers after the call. Grievances pile up
+// under the test's name across -count runs, so tests compare against this
+// instead of the whole sequence.
+func since(t *testing.T) func() []testivus.Disappointment {
+	n := len(testivus.Sequence(t))
+	return func() []testivus.Disappointment { return testivus.Sequence(t)[n:] }
+}
+
+func TestSequence(t *testing.T) {
+	registered := since(t)
+	testivus.Grievance(t, "First, the airing of grievances.")
+	testivus.Grievance(t, "Then, the feats of strength.")
+
+	seq := registered()
+	if len(seq) != 2 {
+		t.Fatalf("got %d grievances, want 2", len(seq))
+	}
+	if seq[0].String() != "First, the airing of grievances." || seq[1].String() != "Then, the feats of strength." {
+		t.Errorf("grievances out of order: %v", seq)
+	}
+}
+
+func TestRetract(t *testing.T) {
+	registered := since(t)
+	testivus.Grievance(t, "You couldn't smell a thing!")
+	g := testivus.Grievance(t, "Festivus is back!")
+
+	if !testivus.Retract(g) {
+		t.Fatal("grievance was not retracted")
+	}
+	if testivus.Retract(g) {
+		t.Error("grievance was retracted twice")
+	}
+	if seq := registered(); len(seq) != 1 {
+		t.Errorf("got %d grievances after retracting, want 1", len(seq))
+	}
+}
+
+func TestMinSeverity(t *testing.T) {
+	testivus.SetMinSeverity(testivus.Major)
+	defer testivus.SetMinSeverity(0)
+
+	registered := since(t)
+	testivus.Grievance(t, "Who's that?")
+	testivus.Grievance(t, "I got a lot of problems with you people!").WithSeverity(testivus.Critical)
+	testivus.Grievance(t, "Serenity now!").WithSeverity(testivus.Major).WithSeverity(testivus.Info)
+
+	if seq := registered(); len(seq) != 1 {
+		t.Errorf("got %d grievances at or above Major, want 1", len(seq))
+	}
+}
+
+func TestSeverityClassifier(t *testing.T) {
+	testivus.SetSeverityClassifier(func(d testivus.Disappointment) testivus.Severity {
+		if strings.Contains(d.String(), "security") {
+			return testivus.Major
+		}
+		return 0
+	})
+	defer testivus.SetSeverityClassifier(nil)
+	testivus.SetMinSeverity(testivus.Major)
+	defer testivus.SetMinSeverity(0)
+
+	registered := since(t)
+	testivus.Grievance(t, "Who's that?")
+	testivus.Grievance(t, "Left the door open.", "security")
+	b := testivus.NewBatch(t)
+	b.Add("Left the window open.", "security")
+	b.Add("Who's there?")
+	b.Flush()
+
+	if seq := registered(); len(seq) != 2 || seq[0].String() != "Left the door open. (security)" || seq[1].String() != "Left the window open. (security)" {
+		t.Errorf("grievances at or above Major = %v", seq)
+	}
+}
+
+// errorTB records Errorf calls instead of failing the real test.
+type errorTB struct {
+	testing.TB
+	errors []string
+}
+
+func (e *errorTB) Errorf(format string, args ...interface{}) {
+	e.errors = append(e.errors, fmt.Sprintf(format, args...))
+}
+
+func TestWatchGoroutines(t *testing.T) {
+	registered := since(t)
+	stop := make(chan struct{})
+	defer close(stop)
+
+	// leak plenty, as goroutines elsewhere in the process come and go too
+	done := testivus.WatchGoroutines(t, 0, "pole")
+	for i := 0; i < 10; i++ {
+		go func() { <-stop }()
+	}
+	done()
+	done()
+
+	seq := registered()
+	if len(seq) != 2 {
+		t.Fatalf("got %d grievances, want 2", len(seq))
+	}
+	for _, g := range seq {
+		if !strings.HasSuffix(g.String(), " goroutines leaked (goroutine-leak, pole)") {
+			t.Errorf("grievance = %q", g)
+		}
+	}
+}
+
+var sink []byte
+
+func TestWatchAllocs(t *testing.T) {
+	registered := since(t)
+	done := testivus.WatchAllocs(t, 1<<10, "tinsel")
+	sink = make([]byte, 1<<20)
+	done()
+	done()
+
+	seq := registered()
+	if len(seq) != 2 {
+		t.Fatalf("got %d grievances, want 2", len(seq))
+	}
+	for _, g := range seq {
+		if !strings.HasSuffix(g.String(), "budget was 1024 (allocs, tinsel)") {
+			t.Errorf("grievance = %q", g)
+		}
+	}
+}
+
+func TestAssertNoNewGrievances(t *testing.T) {
+	testivus.Grievance(t, "Before the change.")
+	testivus.AssertNoNewGrievances(t)()
+
+	tb := &errorTB{TB: t}
+	done := testivus.AssertNoNewGrievances(tb)
+	testivus.Grievance(t, "After the change.")
+	done()
+	if len(tb.errors) != 1 || tb.errors[0] != "1 new grievances registered, want none" {
+		t.Errorf("errors = %q", tb.errors)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	registered := since(t)
+	if !testivus.Check(t, true, "Never registered.") {
+		t.Error("true check returned false")
+	}
+	if testivus.Check(t, false, "Not good enough.", "soft") {
+		t.Error("false check returned true")
+	}
+	if !testivus.CheckEqual(t, []int{1, 2}, []int{1, 2}, "Never registered.") {
+		t.Error("equal check returned false")
+	}
+	if testivus.CheckEqual(t, 3, 4, "Wrong count", "soft") {
+		t.Error("unequal check returned true")
+	}
+
+	seq := registered()
+	if len(seq) != 2 || seq[0].String() != "Not good enough. (soft)" || seq[1].String() != "Wrong count: got 3, want 4 (soft)" {
+		t.Errorf("grievances = %v", seq)
+	}
+}
+
+func TestID(t *testing.T) {
+	a := testivus.Grievance(t, "You're slow!")
+	b := testivus.Grievance(t, "You're slow!")
+
+	if a.ID() == "" || a.ID() == b.ID() {
+		t.Errorf("ids %q and %q should be set and differ", a.ID(), b.ID())
+	}
+	if a.Key() != b.Key() {
+		t.Errorf("keys %q and %q should match", a.Key(), b.Key())
+	}
+}
+
+func BenchmarkGrievance(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		testivus.Grievance(b, "Too much tinsel.", "tinsel")
+	}
+}
+
+func FuzzGrievance(f *testing.F) {
+	f.Add("tinsel")
+	f.Add("")
+	f.Fuzz(func(t *testing.T, decoration string) {
+		if decoration == "" {
+			testivus.Grievance(t, "An aluminum pole needs no decoration.", "fuzz")
+		}
+	})
+}
+
+func TestMarkParallel(t *testing.T) {
+	testivus.MarkParallel(t)
+
+	g := testivus.Grievance(t, "You're slow!", "speed")
+	if got := g.String(); got != "You're slow! (speed, parallel)" {
+		t.Errorf("got %q, want the parallel tag", got)
+	}
+}
+
+func TestConcurrentGrievances(t *testing.T) {
+	const goroutines, each = 10, 100
+
+	for _, name := range []string{"a", "b", "c", "d"} {
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			var wg sync.WaitGroup
+			gs := make(chan testivus.Disappointment, goroutines*each)
+			for i := 0; i < goroutines; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for j := 0; j < each; j++ {
+						gs <- testivus.Grievance(t, "Too many people at the table.", "crowd")
+					}
+				}()
+			}
+			wg.Wait()
+			close(gs)
+
+			if got := len(testivus.Sequence(t)); got != goroutines*each {
+				t.Fatalf("got %d grievances, want %d", got, goroutines*each)
+			}
+
+			// retract them concurrently too, so they don't crowd the report
+			for g := range gs {
+				wg.Add(1)
+				go func(g testivus.Disappointment) {
+					defer wg.Done()
+					testivus.Retract(g)
+				}(g)
+			}
+			wg.Wait()
+
+			if got := len(testivus.Sequence(t)); got != 0 {
+				t.Errorf("got %d grievances after retracting, want 0", got)
+			}
+		})
+	}
+}
+
+func TestAssertMaxFSTouches(t *testing.T) {
+	fsys := fstest.MapFS{"pole.txt": {Data: []byte("aluminum")}}
+
+	touches := testivus.AssertMaxFSTouches(t, fsys, 2, func(fsys fs.FS) {
+		fs.ReadFile(fsys, "pole.txt")
+		fs.Stat(fsys, "pole.txt")
+	})
+	if touches.Reads != 2 || touches.Writes != 0 {
+		t.Errorf("touches = %+v, want 2 reads", touches)
+	}
+}
@@ -0,0 +1,53 @@
+package testivus
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+type timelineRow struct {
+	At        string
+	Test      string
+	Grievance string
+
+	time time.Time
+}
+
+// timeline lists the grievances in the order they were registered, with
+// times as set by -testivus.timeline: relative to the start of the run, like
+// +1.203s, or absolute in RFC 3339. It is empty when the flag is off.
+func (d *disappointments) timeline() []timelineRow {
+	if *timelineFormat == "" {
+		return nil
+	}
+
+	var rows []timelineRow
+	for _, v := range d.Grievances {
+		for _, g := range v {
+			if !g.Time.IsZero() {
+				rows = append(rows, timelineRow{Test: g.Name, Grievance: g.String(), time: g.Time})
+			}
+		}
+	}
+	sort.SliceStable(rows, func(i, j int) bool { return rows[i].time.Before(rows[j].time) })
+
+	start := d.Start
+	if start.IsZero() && len(rows) > 0 {
+		start = rows[0].time
+	}
+	for i, r := range rows {
+		if *timelineFormat == "relative" && durationUnit != 0 {
+			rows[i].At = "+" + formatDuration(r.time.Sub(start))
+		} else if *timelineFormat == "relative" {
+			rows[i].At = fmt.Sprintf("+%.3fs", r.time.Sub(start).Seconds())
+		} else {
+			rows[i].At = r.time.Format(time.RFC3339Nano)
+		}
+	}
+	return rows
+}
+
+// Timeline is every grievance in the order it was registered, when
+// -testivus.timeline is set.
+func (s summary) Timeline() []timelineRow { return s.timeline }
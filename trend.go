@@ -0,0 +1,106 @@
+package testivus
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Trends lists, for every tag in the last n reports, its count in each of
+// them, oldest first. Reports are given oldest first, and n of zero or less
+// means all of them.
+func Trends(reports []*Report, n int) map[string][]int {
+	if n > 0 && len(reports) > n {
+		reports = reports[len(reports)-n:]
+	}
+
+	trends := make(map[string][]int)
+	for _, r := range reports {
+		for tag := range r.ByTag() {
+			trends[tag] = nil
+		}
+	}
+	for tag := range trends {
+		counts := make([]int, len(reports))
+		for i, r := range reports {
+			counts[i] = r.ByTag()[tag]
+		}
+		trends[tag] = counts
+	}
+	return trends
+}
+
+// DescribeTrends renders the trends one tag per line, sorted by tag, as a
+// sparkline such as "speed ▁▃█ 1 -> 7". When the locale isn't UTF-8 the
+// counts are listed instead.
+func DescribeTrends(trends map[string][]int) string {
+	tags := make([]string, 0, len(trends))
+	for tag := range trends {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+
+	spark := utf8Locale()
+	var buf bytes.Buffer
+	w := newTabWriter(&buf)
+	for _, tag := range tags {
+		counts := trends[tag]
+		if len(counts) == 0 {
+			continue
+		}
+		if spark {
+			fmt.Fprintf(w, "%s\t%s\t%d -> %d\n", tag, sparkline(counts), counts[0], counts[len(counts)-1])
+		} else {
+			fmt.Fprintf(w, "%s\t%s\n", tag, joinCounts(counts))
+		}
+	}
+	w.Flush()
+
+	return buf.String()
+}
+
+// sparkBars are the sparkline levels, lowest first.
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// sparkline draws counts scaled from zero to the largest of them.
+func sparkline(counts []int) string {
+	max := 0
+	for _, c := range counts {
+		if c > max {
+			max = c
+		}
+	}
+
+	var b strings.Builder
+	for _, c := range counts {
+		level := 0
+		if max > 0 {
+			level = c * (len(sparkBars) - 1) / max
+		}
+		b.WriteRune(sparkBars[level])
+	}
+	return b.String()
+}
+
+func joinCounts(counts []int) string {
+	s := make([]string, len(counts))
+	for i, c := range counts {
+		s[i] = strconv.Itoa(c)
+	}
+	return strings.Join(s, " ")
+}
+
+// utf8Locale reports whether the locale, going by the first of LC_ALL,
+// LC_CTYPE and LANG that is set, uses UTF-8. With none set it is assumed to,
+// as the rest of the report already does.
+func utf8Locale() bool {
+	for _, env := range []string{"LC_ALL", "LC_CTYPE", "LANG"} {
+		if v := strings.ToLower(os.Getenv(env)); v != "" {
+			return strings.Contains(v, "utf-8") || strings.Contains(v, "utf8")
+		}
+	}
+	return true
+}
@@ -0,0 +1,55 @@
+package testivus
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// uploaders send a finished report to a URL, by scheme. The SDK behind each
+// is only built with its tag, so the files built with those tags register
+// them.
+var uploaders = map[string]func(url string, data []byte) error{}
+
+// uploadTags are the build tags that add the uploader for each scheme.
+var uploadTags = map[string]string{
+	"s3": "s3",
+	"gs": "gcs",
+}
+
+// uploadScheme returns the scheme of path if it is a URL testivus can upload
+// to, such as s3://bucket/key.json. Local paths have none.
+func uploadScheme(path string) (string, bool) {
+	i := strings.Index(path, "://")
+	if i <= 0 {
+		return "", false
+	}
+	_, ok := uploadTags[path[:i]]
+	return path[:i], ok
+}
+
+// upload renders a report in memory and hands it to the uploader for scheme.
+func upload(scheme, url string, write func(w io.Writer) error) error {
+	up, ok := uploaders[scheme]
+	if !ok {
+		return fmt.Errorf("cannot write %s: build with -tags %s to upload to %s:// URLs", url, uploadTags[scheme], scheme)
+	}
+
+	var buf bytes.Buffer
+	if err := write(&buf); err != nil {
+		return err
+	}
+	return up(url, buf.Bytes())
+}
+
+// splitBucketURL splits a URL like s3://bucket/path/to/key into its bucket
+// and key.
+func splitBucketURL(url string) (bucket, key string, err error) {
+	rest := url[strings.Index(url, "://")+3:]
+	i := strings.Index(rest, "/")
+	if i <= 0 || i == len(rest)-1 {
+		return "", "", fmt.Errorf("expected scheme://bucket/key, got %q", url)
+	}
+	return rest[:i], rest[i+1:], nil
+}
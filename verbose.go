@@ -0,0 +1,33 @@
+package testivus
+
+import (
+	"flag"
+	"sync"
+	"testing"
+)
+
+var verboseFlag = flag.Bool("testivus.verbose", false, "print grievances as they happen and the detailed report, regardless of go test -v")
+
+var (
+	verboseOnce sync.Once
+	isVerbose   bool
+)
+
+// verbose reports whether testivus prints grievances as they happen and the
+// detailed report. -testivus.verbose decides when it is given, either way;
+// otherwise it follows go test -v. It is worked out once the flags are
+// parsed, as it is asked for every grievance.
+func verbose() bool {
+	if !flag.Parsed() {
+		return lookupVerbose()
+	}
+	verboseOnce.Do(func() { isVerbose = lookupVerbose() })
+	return isVerbose
+}
+
+func lookupVerbose() bool {
+	if flagGiven("testivus.verbose") {
+		return *verboseFlag
+	}
+	return testing.Verbose()
+}
@@ -0,0 +1,121 @@
+package testivus
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// goroutineSettle is how long WatchGoroutines waits for goroutines to exit
+// before calling them leaked.
+const goroutineSettle = 100 * time.Millisecond
+
+// WatchGoroutines counts goroutines now and again when the returned func is
+// called. If more than tolerance goroutines were left behind, a grievance
+// tagged goroutine-leak is registered with the delta as a field. Goroutines
+// are given a short time to exit first, so the scheduler doesn't cause false
+// alarms.
+//
+//	defer testivus.WatchGoroutines(t, 0)()
+func WatchGoroutines(t testing.TB, tolerance int, tags ...string) func() {
+	before := runtime.NumGoroutine()
+	return func() {
+		t.Helper()
+
+		delta := runtime.NumGoroutine() - before
+		for deadline := time.Now().Add(goroutineSettle); delta > tolerance && time.Now().Before(deadline); {
+			time.Sleep(10 * time.Millisecond)
+			delta = runtime.NumGoroutine() - before
+		}
+		if delta <= tolerance {
+			return
+		}
+
+		Grievance(t, fmt.Sprintf("%d goroutines leaked", delta), append([]string{"goroutine-leak"}, tags...)...).WithField("goroutines", delta)
+	}
+}
+
+// WatchAllocs measures the bytes allocated between the call and when the
+// returned func is called. If more than maxBytes were allocated, a grievance
+// tagged allocs is registered with the allocated bytes as a field.
+//
+// The measurement comes from runtime.ReadMemStats, so it includes allocations
+// made by every goroutine in the process, including parallel tests, and each
+// call briefly stops the world. It is a coarse budget, not a profiler.
+//
+//	defer testivus.WatchAllocs(t, 1<<20)()
+func WatchAllocs(t testing.TB, maxBytes uint64, tags ...string) func() {
+	var before runtime.MemStats
+	runtime.ReadMemStats(&before)
+	return func() {
+		t.Helper()
+
+		var after runtime.MemStats
+		runtime.ReadMemStats(&after)
+		allocated := after.TotalAlloc - before.TotalAlloc
+		if allocated <= maxBytes {
+			return
+		}
+
+		Grievance(t, fmt.Sprintf("allocated %d bytes, budget was %d", allocated, maxBytes), append([]string{"allocs"}, tags...)...).WithField("bytes", allocated)
+	}
+}
+
+// AssertNoNewGrievances counts the grievances registered so far and fails t
+// if there are more when the returned func is called. The count covers the
+// whole run, so grievances from parallel tests count too.
+//
+//	defer testivus.AssertNoNewGrievances(t)()
+func AssertNoNewGrievances(t testing.TB) func() {
+	before := running.total()
+	return func() {
+		t.Helper()
+
+		if after := running.total(); after > before {
+			t.Errorf("%d new grievances registered, want none", after-before)
+		}
+	}
+}
+
+// total counts the grievances stored so far.
+func (d *disappointments) total() int {
+	d.Lock()
+	defer d.Unlock()
+
+	d.recheck()
+	n := 0
+	for _, c := range d.stored {
+		n += c
+	}
+	return n
+}
+
+// Check is a soft assertion: when cond is false it registers a grievance
+// instead of failing t, so the checks pile up in the report. It returns cond.
+//
+//	testivus.Check(t, len(items) < 100, "Too many items.", "size")
+func Check(t testing.TB, cond bool, msg string, tags ...string) bool {
+	t.Helper()
+	if !cond {
+		Grievance(t, msg, tags...)
+	}
+	return cond
+}
+
+// CheckEqual is Check for got and want being deeply equal. The grievance
+// message shows both values, which are also recorded as fields in Go syntax.
+func CheckEqual(t testing.TB, got, want interface{}, msg string, tags ...string) bool {
+	t.Helper()
+	if reflect.DeepEqual(got, want) {
+		return true
+	}
+
+	// the fields are rendered as Go syntax, since not every value can be
+	// encoded in the JSON report
+	Grievance(t, fmt.Sprintf("%s: got %v, want %v", msg, got, want), tags...).
+		WithField("got", fmt.Sprintf("%#v", got)).
+		WithField("want", fmt.Sprintf("%#v", want))
+	return false
+}